@@ -0,0 +1,84 @@
+// hostpaths-learn generates the allowedHostPaths settings of the hostpaths-psp
+// policy accepting the hostPath volumes used by existing workloads.
+//
+// Usage:
+//
+//	hostpaths-learn [-depth N] [-format yaml|json] [-settings FILE] PATH...
+//
+// The paths are JSON files, or directories scanned recursively, holding
+// Kubernetes objects or Lists of them like the output of `kubectl get -o json`.
+// Every hostPath mounted by their pod specs is collected, with its readOnly
+// attribute, and a minimal list of allowedHostPaths entries accepting them is
+// printed.
+//
+// The paths are generalized to their ancestor with -depth components, 2 by
+// default: a workload mounting `/var/log/pods` gets a `/var/log` entry. Use
+// -depth 0 to keep the exact paths. Deeper entries are added when workloads
+// mount paths under the same prefix with different readOnly attributes.
+//
+// The yaml format prints the settings with comments listing the workloads
+// motivating each entry. The json format prints the settings alone, and the
+// workloads to the standard error. The podSpecPaths of the -settings file are
+// used to find the pod specs of custom resources, and all its settings but the
+// allowedHostPaths are copied to the generated ones. The command fails when
+// the generated settings would be rejected by the policy.
+//
+// PersistentVolumes are not learned, their hostPath is not mounted by the
+// workloads directly.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+	"github.com/kubewarden/go-policy-template/internal/learn"
+)
+
+func main() {
+	depth := flag.Int("depth", 2, "number of path components of the generalized entries, 0 keeps the exact paths")
+	format := flag.String("format", "yaml", "output format, one of: yaml, json")
+	settingsFile := flag.String("settings", "", "file holding JSON settings whose podSpecPaths are used, and copied with the other settings but allowedHostPaths")
+	flag.Parse()
+
+	if err := run(flag.Args(), *depth, *format, *settingsFile, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "hostpaths-learn: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(paths []string, depth int, format, settingsFile string, w, motivations io.Writer) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no file or directory to learn from")
+	}
+	if depth < 0 {
+		return fmt.Errorf("invalid -depth %d, it must not be negative", depth)
+	}
+	if format != "yaml" && format != "json" {
+		return fmt.Errorf("unknown format '%s', it must be one of: yaml, json", format)
+	}
+
+	settings := hostpaths.Settings{}
+	if settingsFile != "" {
+		payload, err := os.ReadFile(settingsFile)
+		if err != nil {
+			return err
+		}
+		if settings, err = hostpaths.ValidateSettingsPayload(payload); err != nil {
+			return fmt.Errorf("invalid settings %s: %w", settingsFile, err)
+		}
+	}
+
+	usages, err := learn.Collect(paths, settings)
+	if err != nil {
+		return err
+	}
+	entries := learn.Learn(usages, depth)
+
+	if format == "json" {
+		return learn.WriteJSON(w, motivations, entries, settings)
+	}
+	return learn.WriteYAML(w, entries, settings)
+}
@@ -0,0 +1,116 @@
+// hostpaths-replay predicts the impact of new settings of the hostpaths-psp
+// policy, replaying the requests recorded by Kubernetes audit logs.
+//
+// Usage:
+//
+//	hostpaths-replay -current FILE -proposed FILE [AUDIT-LOG...]
+//
+// The current and proposed files hold the JSON settings of the policy. The
+// audit logs are JSON lines files, read from the standard input when no file
+// is given. The CREATE and UPDATE requests of the logs are evaluated against
+// both settings, and a summary of the requests newly rejected, newly accepted
+// and unchanged is printed, followed by the details of the changed ones.
+//
+// The objects are taken from the `responseObject` of the events when logged,
+// from their `requestObject` otherwise: the audit policy must log the events
+// of the validated resources at the Request or RequestResponse level. Patch
+// requests are only replayed when logged at the RequestResponse level.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+	"github.com/kubewarden/go-policy-template/internal/replay"
+)
+
+func main() {
+	currentFile := flag.String("current", "", "file holding the JSON settings currently deployed")
+	proposedFile := flag.String("proposed", "", "file holding the proposed JSON settings")
+	flag.Parse()
+
+	if err := run(flag.Args(), *currentFile, *proposedFile, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "hostpaths-replay: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(files []string, currentFile, proposedFile string, w io.Writer) error {
+	current, err := readSettings("-current", currentFile)
+	if err != nil {
+		return err
+	}
+	proposed, err := readSettings("-proposed", proposedFile)
+	if err != nil {
+		return err
+	}
+
+	events, stats, err := readAuditLogs(files)
+	if err != nil {
+		return err
+	}
+	diff, err := replay.Compare(events, current, proposed)
+	if err != nil {
+		return err
+	}
+	diff.Stats = stats
+
+	return replay.WriteDiff(w, diff)
+}
+
+// readAuditLogs reads the given audit logs one by one, or the standard input
+// when no file is given.
+func readAuditLogs(files []string) ([]replay.Event, replay.Stats, error) {
+	if len(files) == 0 {
+		events, stats, err := replay.ReadAuditLog(os.Stdin)
+		if err != nil {
+			return nil, stats, fmt.Errorf("standard input: %w", err)
+		}
+		return events, stats, nil
+	}
+
+	events := make([]replay.Event, 0)
+	stats := replay.Stats{}
+	for _, file := range files {
+		fileEvents, fileStats, err := readAuditLog(file)
+		if err != nil {
+			return nil, stats, err
+		}
+		events = append(events, fileEvents...)
+		stats.Skipped += fileStats.Skipped
+		stats.Incomplete += fileStats.Incomplete
+	}
+	return events, stats, nil
+}
+
+func readAuditLog(file string) ([]replay.Event, replay.Stats, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, replay.Stats{}, err
+	}
+	defer f.Close()
+
+	events, stats, err := replay.ReadAuditLog(f)
+	if err != nil {
+		return nil, stats, fmt.Errorf("%s: %w", file, err)
+	}
+	return events, stats, nil
+}
+
+func readSettings(flagName, file string) (hostpaths.Settings, error) {
+	if file == "" {
+		return hostpaths.Settings{}, fmt.Errorf("the %s flag is required", flagName)
+	}
+	payload, err := os.ReadFile(file)
+	if err != nil {
+		return hostpaths.Settings{}, err
+	}
+	settings, err := hostpaths.ValidateSettingsPayload(payload)
+	if err != nil {
+		return hostpaths.Settings{}, fmt.Errorf("invalid settings %s: %w", file, err)
+	}
+	return settings, nil
+}
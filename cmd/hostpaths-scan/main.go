@@ -0,0 +1,113 @@
+// hostpaths-scan evaluates the hostpaths-psp policy against Kubernetes
+// manifests, without a Wasm runtime nor a cluster.
+//
+// Usage:
+//
+//	hostpaths-scan -settings FILE [-format FORMAT] [-metadata FILE] [PATH...]
+//
+// The settings file holds the JSON settings of the policy. The paths are JSON
+// files, or directories walked recursively looking for `.json` files; the
+// current directory is scanned when no path is given. Files can hold single
+// objects or the List produced by `kubectl get -o json`, the other JSON
+// documents are skipped with a warning on the standard error.
+//
+// Every hostPath violation is reported on the standard output, with the file
+// and the path of the offending pod spec inside of it, using the `text`,
+// `json`, `sarif` or `junit` format. The `policyreport` format reports all the
+// evaluated objects as `wgpolicyk8s.io/v1alpha2` PolicyReport objects instead,
+// one per namespace. The severity of the violations is read
+// from the `io.kubewarden.policy.severity` annotation of the policy
+// metadata.yml file.
+//
+// The command exits with status 1 when violations are found, and with status
+// 2 on errors. PersistentVolumeClaims are not resolved, whatever the value of
+// the `checkPersistentVolumeClaims` setting.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+	"github.com/kubewarden/go-policy-template/internal/scan"
+)
+
+// defaultMetadataFile is read when the -metadata flag is not given, if it
+// exists.
+const defaultMetadataFile = "metadata.yml"
+
+func main() {
+	settingsFile := flag.String("settings", "", "file holding the JSON settings of the policy")
+	format := flag.String("format", "text", "output format, one of: "+strings.Join(scan.Formats, ", "))
+	metadataFile := flag.String("metadata", "", "metadata.yml file of the policy, defaults to "+defaultMetadataFile+" when it exists")
+	flag.Parse()
+
+	violations, err := run(flag.Args(), *settingsFile, *format, *metadataFile, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hostpaths-scan: %v\n", err)
+		os.Exit(2)
+	}
+	if violations {
+		os.Exit(1)
+	}
+}
+
+func run(paths []string, settingsFile, format, metadataFile string, w io.Writer) (bool, error) {
+	if settingsFile == "" {
+		return false, fmt.Errorf("the -settings flag is required")
+	}
+	payload, err := os.ReadFile(settingsFile)
+	if err != nil {
+		return false, err
+	}
+	settings, err := hostpaths.ValidateSettingsPayload(payload)
+	if err != nil {
+		return false, fmt.Errorf("invalid settings: %w", err)
+	}
+
+	metadata, err := readMetadata(metadataFile)
+	if err != nil {
+		return false, err
+	}
+
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	objects, err := scan.Scan(paths, settings)
+	if err != nil {
+		return false, err
+	}
+
+	if err = scan.Write(w, format, objects, metadata); err != nil {
+		return false, err
+	}
+	for _, object := range objects {
+		if len(object.Violations) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func readMetadata(metadataFile string) (scan.Metadata, error) {
+	optional := metadataFile == ""
+	if optional {
+		metadataFile = defaultMetadataFile
+	}
+
+	f, err := os.Open(metadataFile)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return scan.Metadata{}, nil
+	}
+	if err != nil {
+		return scan.Metadata{}, err
+	}
+	defer f.Close()
+
+	return scan.ReadMetadata(f)
+}
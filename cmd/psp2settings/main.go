@@ -0,0 +1,56 @@
+// psp2settings translates PodSecurityPolicy objects into settings and
+// ClusterAdmissionPolicy manifests of the hostpaths-psp policy.
+//
+// Usage:
+//
+//	psp2settings [-output-dir DIR] [-module URL] [FILE...]
+//
+// The PodSecurityPolicy objects are read from the given files, or from the
+// standard input when no file is given, either as single objects or as the
+// List produced by `kubectl get psp -o json`. For each PodSecurityPolicy, the
+// files `<name>-settings.json` and `<name>-policy.json` are written inside of
+// the output directory. The PodSecurityPolicy features that cannot be
+// translated are reported on the standard error.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kubewarden/go-policy-template/internal/convert"
+)
+
+func main() {
+	outputDir := flag.String("output-dir", ".", "directory where the generated files are written")
+	module := flag.String("module", convert.DefaultModule, "policy module used by the generated policy manifests")
+	flag.Parse()
+
+	if err := run(flag.Args(), *outputDir, *module); err != nil {
+		fmt.Fprintf(os.Stderr, "psp2settings: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(files []string, outputDir, module string) error {
+	readers := make([]io.Reader, 0, len(files))
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	if len(readers) == 0 {
+		readers = append(readers, os.Stdin)
+	}
+
+	conversions, err := convert.FromPodSecurityPolicies(io.MultiReader(readers...))
+	if err != nil {
+		return err
+	}
+
+	return convert.WriteFiles(conversions, "PodSecurityPolicy", outputDir, module, os.Stderr)
+}
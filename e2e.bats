@@ -47,3 +47,40 @@
   [ "$status" -eq 0 ]
   [ $(expr "$output" : '.*allowed.*true') -ne 0 ]
 }
+
+@test "reject because /data is not in settings for custom resource" {
+  run kwctl run annotated-policy.wasm -r test_data/request-rollout-hostpaths.json \
+    --settings-json \
+    '{ "allowedHostPaths": [
+           {"pathPrefix": "/var","readOnly": true}
+        ],
+       "podSpecPaths": [
+           {"group": "argoproj.io", "kind": "Rollout", "paths": ["spec.template.spec"]}
+        ]
+     }'
+
+  # this prints the output when one the checks below fails
+  echo "output = ${output}"
+
+  # request rejected
+  [ "$status" -eq 0 ]
+  [ $(expr "$output" : '.*allowed.*false') -ne 0 ]
+  [ $(expr "$output" : ".*spec.template.spec: hostPath '/data' mounted as 'test-data' is not in the AllowedHostPaths list.*") -ne 0 ]
+}
+
+@test "reject because the local PersistentVolume is not in settings" {
+  run kwctl run annotated-policy.wasm -r test_data/request-pv-local.json \
+    --settings-json \
+    '{ "allowedHostPaths": [
+           {"pathPrefix": "/data","readOnly": true}
+        ]
+     }'
+
+  # this prints the output when one the checks below fails
+  echo "output = ${output}"
+
+  # request rejected
+  [ "$status" -eq 0 ]
+  [ $(expr "$output" : '.*allowed.*false') -ne 0 ]
+  [ $(expr "$output" : ".*hostPath '/mnt/disks/ssd1' of PersistentVolume 'pv-local' is not in the AllowedHostPaths list.*") -ne 0 ]
+}
@@ -0,0 +1,121 @@
+// Package convert translates the legacy policies controlling the usage of
+// hostPath volumes into settings of the hostpaths-psp policy.
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+)
+
+// DefaultModule is the location of the policy module used by the generated
+// policy manifests.
+const DefaultModule = "registry://ghcr.io/kubewarden/policies/hostpaths-psp:latest"
+
+// Conversion is the result of the translation of a legacy policy.
+type Conversion struct {
+	// Name of the legacy policy
+	Name     string
+	Settings hostpaths.Settings
+	// Mode of the policy, empty for the default protect mode
+	Mode string
+	// NamespaceSelector and ObjectSelector restrict the resources the
+	// policy applies to, nil means all of them
+	NamespaceSelector *hostpaths.LabelSelector
+	ObjectSelector    *hostpaths.LabelSelector
+	// Warnings lists the features of the legacy policy that cannot be
+	// translated
+	Warnings []string
+}
+
+// validate ensures the generated settings are accepted by the policy.
+func (c *Conversion) validate() error {
+	payload, err := json.Marshal(c.Settings)
+	if err != nil {
+		return err
+	}
+	if _, err = hostpaths.ValidateSettingsPayload(payload); err != nil {
+		return fmt.Errorf("generated settings for '%s' are not valid: %w", c.Name, err)
+	}
+	return nil
+}
+
+// appendHostPath appends the given legacy entry to the AllowedHostPaths
+// entries. An entry with the same pathPrefix is merged into the existing one,
+// which is writable when any of them is: the legacy policies accept the
+// mounts allowed by any of their entries, while the policy rejects the
+// entries sharing a pathPrefix.
+func appendHostPath(hostPaths []hostpaths.HostPath, entry allowedHostPath) []hostpaths.HostPath {
+	for i := range hostPaths {
+		if hostpaths.HasPathPrefix(hostPaths[i].PathPrefix, entry.PathPrefix) &&
+			hostpaths.HasPathPrefix(entry.PathPrefix, hostPaths[i].PathPrefix) {
+			hostPaths[i].ReadOnly = hostPaths[i].ReadOnly && entry.ReadOnly
+			return hostPaths
+		}
+	}
+	return append(hostPaths, hostpaths.HostPath{
+		PathPrefix: entry.PathPrefix,
+		ReadOnly:   entry.ReadOnly,
+	})
+}
+
+// object holds the fields shared by all the Kubernetes objects, and the items
+// of List objects.
+type object struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Items []json.RawMessage `json:"items"`
+}
+
+// decodeObjects reads all the JSON documents of the given reader, and returns
+// the raw objects of the given kind. The items of List objects are flattened.
+func decodeObjects(r io.Reader, kind string) ([]json.RawMessage, error) {
+	objects := make([]json.RawMessage, 0)
+	decoder := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		found, err := filterObjects(raw, kind)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, found...)
+	}
+}
+
+func filterObjects(raw json.RawMessage, kind string) ([]json.RawMessage, error) {
+	obj := object{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+
+	switch obj.Kind {
+	case kind:
+		return []json.RawMessage{raw}, nil
+	case "List", kind + "List":
+		objects := make([]json.RawMessage, 0)
+		for _, item := range obj.Items {
+			found, err := filterObjects(item, kind)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, found...)
+		}
+		return objects, nil
+	default:
+		return nil, fmt.Errorf("unexpected object of kind '%s', expected %s or List", obj.Kind, kind)
+	}
+}
@@ -0,0 +1,205 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+)
+
+// namespaceNameLabel is the label set by Kubernetes on every namespace,
+// holding its name.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+type gatekeeperKinds struct {
+	APIGroups []string `json:"apiGroups"`
+	Kinds     []string `json:"kinds"`
+}
+
+type gatekeeperMatch struct {
+	Kinds              []gatekeeperKinds        `json:"kinds"`
+	Namespaces         []string                 `json:"namespaces"`
+	ExcludedNamespaces []string                 `json:"excludedNamespaces"`
+	LabelSelector      *hostpaths.LabelSelector `json:"labelSelector"`
+	NamespaceSelector  *hostpaths.LabelSelector `json:"namespaceSelector"`
+	Scope              string                   `json:"scope"`
+	Name               string                   `json:"name"`
+}
+
+type hostFilesystemConstraint struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		EnforcementAction string          `json:"enforcementAction"`
+		Match             gatekeeperMatch `json:"match"`
+		Parameters        struct {
+			AllowedHostPaths []allowedHostPath `json:"allowedHostPaths"`
+		} `json:"parameters"`
+	} `json:"spec"`
+}
+
+// FromGatekeeperConstraints translates all the Gatekeeper
+// K8sPSPHostFilesystem constraints read from r, either single objects or List
+// ones, into settings. The `match` block of the constraints is translated into
+// the namespace and object selectors of the policy.
+func FromGatekeeperConstraints(r io.Reader) ([]Conversion, error) {
+	objects, err := decodeObjects(r, "K8sPSPHostFilesystem")
+	if err != nil {
+		return nil, err
+	}
+
+	conversions := make([]Conversion, 0, len(objects))
+	for _, raw := range objects {
+		conversion, err := fromGatekeeperConstraint(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err = conversion.validate(); err != nil {
+			return nil, err
+		}
+		conversions = append(conversions, conversion)
+	}
+	return conversions, nil
+}
+
+func fromGatekeeperConstraint(raw json.RawMessage) (Conversion, error) {
+	constraint := hostFilesystemConstraint{}
+	if err := json.Unmarshal(raw, &constraint); err != nil {
+		return Conversion{}, err
+	}
+
+	conversion := Conversion{
+		Name: constraint.Metadata.Name,
+		Settings: hostpaths.Settings{
+			AllowedHostPaths: make([]hostpaths.HostPath, 0),
+		},
+		Warnings: make([]string, 0),
+	}
+
+	allowedHostPaths := constraint.Spec.Parameters.AllowedHostPaths
+	if len(allowedHostPaths) == 0 {
+		conversion.Warnings = append(conversion.Warnings,
+			"spec.parameters.allowedHostPaths: an empty list forbids all the host paths, the hostpaths-psp policy allows all of them instead")
+	}
+	for _, entry := range allowedHostPaths {
+		conversion.Settings.AllowedHostPaths = appendHostPath(conversion.Settings.AllowedHostPaths, entry)
+		if !entry.ReadOnly {
+			// Gatekeeper accepts read-only mounts of writable prefixes,
+			// the policy does not
+			conversion.Warnings = append(conversion.Warnings, fmt.Sprintf(
+				"spec.parameters.allowedHostPaths: read-only mounts of '%s' will be rejected, the policy requires them to be writable",
+				entry.PathPrefix))
+		}
+	}
+
+	switch constraint.Spec.EnforcementAction {
+	case "", "deny":
+	case "dryrun", "warn":
+		conversion.Mode = "monitor"
+	default:
+		conversion.Warnings = append(conversion.Warnings, fmt.Sprintf(
+			"spec.enforcementAction '%s' has no equivalent, the policy is deployed in protect mode",
+			constraint.Spec.EnforcementAction))
+	}
+
+	conversion.Warnings = append(conversion.Warnings, convertMatch(constraint.Spec.Match, &conversion)...)
+
+	return conversion, nil
+}
+
+// convertMatch translates the match block of a constraint into the selectors
+// of the conversion, and returns the warnings about the criteria that cannot
+// be translated.
+func convertMatch(match gatekeeperMatch, conversion *Conversion) []string {
+	warnings := make([]string, 0)
+
+	namespaceSelector := &hostpaths.LabelSelector{}
+	if match.NamespaceSelector != nil {
+		namespaceSelector.MatchLabels = match.NamespaceSelector.MatchLabels
+		namespaceSelector.MatchExpressions = append(namespaceSelector.MatchExpressions,
+			match.NamespaceSelector.MatchExpressions...)
+	}
+
+	namespaces, namespacePrefixes := splitNamespaceGlobs(match.Namespaces)
+	if len(namespaces) > 0 {
+		namespaceSelector.MatchExpressions = append(namespaceSelector.MatchExpressions, hostpaths.LabelSelectorRequirement{
+			Key:      namespaceNameLabel,
+			Operator: hostpaths.SelectorOpIn,
+			Values:   namespaces,
+		})
+	}
+	for _, prefix := range namespacePrefixes {
+		warnings = append(warnings, fmt.Sprintf(
+			"spec.match.namespaces: the '%s' prefix cannot be translated into a namespace selector", prefix))
+	}
+
+	excludedNamespaces, excludedPrefixes := splitNamespaceGlobs(match.ExcludedNamespaces)
+	if len(excludedNamespaces) > 0 {
+		namespaceSelector.MatchExpressions = append(namespaceSelector.MatchExpressions, hostpaths.LabelSelectorRequirement{
+			Key:      namespaceNameLabel,
+			Operator: hostpaths.SelectorOpNotIn,
+			Values:   excludedNamespaces,
+		})
+	}
+	for _, prefix := range excludedPrefixes {
+		warnings = append(warnings, fmt.Sprintf(
+			"spec.match.excludedNamespaces: the '%s' prefix cannot be translated into a namespace selector", prefix))
+	}
+
+	if len(namespaceSelector.MatchLabels) > 0 || len(namespaceSelector.MatchExpressions) > 0 {
+		conversion.NamespaceSelector = namespaceSelector
+	}
+	conversion.ObjectSelector = match.LabelSelector
+
+	if len(match.Kinds) > 0 && !matchesAllPodKinds(match.Kinds) {
+		warnings = append(warnings,
+			"spec.match.kinds: Pods are not matched, the hostpaths-psp policy validates Pods and all the workload resources")
+	}
+	if match.Scope != "" && match.Scope != "*" && match.Scope != "Namespaced" {
+		warnings = append(warnings, fmt.Sprintf("spec.match.scope '%s' has no equivalent", match.Scope))
+	}
+	if match.Name != "" {
+		warnings = append(warnings, fmt.Sprintf("spec.match.name '%s' has no equivalent", match.Name))
+	}
+
+	return warnings
+}
+
+// splitNamespaceGlobs splits the Gatekeeper namespace list into plain names
+// and prefix globs, like `kube-*`.
+func splitNamespaceGlobs(namespaces []string) ([]string, []string) {
+	names := make([]string, 0)
+	prefixes := make([]string, 0)
+	for _, namespace := range namespaces {
+		if strings.HasSuffix(namespace, "*") || strings.HasPrefix(namespace, "*") {
+			prefixes = append(prefixes, namespace)
+			continue
+		}
+		names = append(names, namespace)
+	}
+	sort.Strings(names)
+	return names, prefixes
+}
+
+// matchesAllPodKinds returns whether the kinds matched by a constraint cover
+// the Pods, the only kind the Gatekeeper template inspects.
+func matchesAllPodKinds(kinds []gatekeeperKinds) bool {
+	for _, kind := range kinds {
+		groupMatches := false
+		for _, group := range kind.APIGroups {
+			if group == "" || group == "*" {
+				groupMatches = true
+			}
+		}
+		for _, k := range kind.Kinds {
+			if groupMatches && (k == "Pod" || k == "*") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// gatekeeperGolden is the content of the golden files of the Gatekeeper
+// conversions: the policy manifest and the warnings of each constraint.
+type gatekeeperGolden struct {
+	Policy   json.RawMessage `json:"policy"`
+	Warnings []string        `json:"warnings"`
+}
+
+func TestFromGatekeeperConstraints(t *testing.T) {
+	inputs, err := filepath.Glob("../../test_data/gatekeeper/*.json")
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	for _, input := range inputs {
+		if strings.HasSuffix(input, ".golden.json") {
+			continue
+		}
+		golden := strings.TrimSuffix(input, ".json") + ".golden.json"
+
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			f, err := os.Open(input)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+			defer f.Close()
+
+			conversions, err := FromGatekeeperConstraints(f)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			results := make([]gatekeeperGolden, 0, len(conversions))
+			for _, conversion := range conversions {
+				policy, err := PolicyManifest(conversion, DefaultModule)
+				if err != nil {
+					t.Fatalf("Unexpected error %+v", err)
+				}
+				results = append(results, gatekeeperGolden{
+					Policy:   policy,
+					Warnings: conversion.Warnings,
+				})
+			}
+			actual, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+			actual = append(actual, '\n')
+
+			if *update {
+				if err = os.WriteFile(golden, actual, 0o644); err != nil {
+					t.Fatalf("Unexpected error %+v", err)
+				}
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+			if !bytes.Equal(actual, expected) {
+				t.Errorf("Conversion of %s doesn't match %s, got:\n%s", input, golden, actual)
+			}
+		})
+	}
+}
+
+func TestFromGatekeeperConstraintsWithUnexpectedKind(t *testing.T) {
+	_, err := FromGatekeeperConstraints(strings.NewReader(`{"apiVersion": "v1", "kind": "Pod"}`))
+	expected := "unexpected object of kind 'Pod', expected K8sPSPHostFilesystem or List"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Wanted error '%s', but got '%v' instead", expected, err)
+	}
+}
@@ -0,0 +1,132 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+)
+
+// rule mirrors the rules declared inside of the policy metadata.yml.
+type rule struct {
+	APIGroups   []string `json:"apiGroups"`
+	APIVersions []string `json:"apiVersions"`
+	Resources   []string `json:"resources"`
+	Operations  []string `json:"operations"`
+}
+
+var policyRules = []rule{
+	{
+		APIGroups:   []string{""},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"pods", "pods/ephemeralcontainers"},
+		Operations:  []string{"CREATE", "UPDATE"},
+	},
+	{
+		APIGroups:   []string{""},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"replicationcontrollers", "podtemplates"},
+		Operations:  []string{"CREATE", "UPDATE"},
+	},
+	{
+		APIGroups:   []string{""},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"persistentvolumes"},
+		Operations:  []string{"CREATE", "UPDATE"},
+	},
+	{
+		APIGroups:   []string{"apps"},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"deployments", "replicasets", "statefulsets", "daemonsets"},
+		Operations:  []string{"CREATE", "UPDATE"},
+	},
+	{
+		APIGroups:   []string{"batch"},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"jobs", "cronjobs"},
+		Operations:  []string{"CREATE", "UPDATE"},
+	},
+}
+
+type metadata struct {
+	Name string `json:"name"`
+}
+
+type clusterAdmissionPolicySpec struct {
+	Module            string                   `json:"module"`
+	Mode              string                   `json:"mode,omitempty"`
+	Rules             []rule                   `json:"rules"`
+	Mutating          bool                     `json:"mutating"`
+	NamespaceSelector *hostpaths.LabelSelector `json:"namespaceSelector,omitempty"`
+	ObjectSelector    *hostpaths.LabelSelector `json:"objectSelector,omitempty"`
+	Settings          hostpaths.Settings       `json:"settings"`
+}
+
+type clusterAdmissionPolicy struct {
+	APIVersion string                     `json:"apiVersion"`
+	Kind       string                     `json:"kind"`
+	Metadata   metadata                   `json:"metadata"`
+	Spec       clusterAdmissionPolicySpec `json:"spec"`
+}
+
+// PolicyManifest returns the ClusterAdmissionPolicy enforcing the settings of
+// the conversion, running the policy module found at the given location.
+func PolicyManifest(conversion Conversion, module string) ([]byte, error) {
+	policy := clusterAdmissionPolicy{
+		APIVersion: "policies.kubewarden.io/v1",
+		Kind:       "ClusterAdmissionPolicy",
+		Metadata: metadata{
+			Name: "hostpaths-psp-" + conversion.Name,
+		},
+		Spec: clusterAdmissionPolicySpec{
+			Module:            module,
+			Mode:              conversion.Mode,
+			Rules:             policyRules,
+			Mutating:          false,
+			NamespaceSelector: conversion.NamespaceSelector,
+			ObjectSelector:    conversion.ObjectSelector,
+			Settings:          conversion.Settings,
+		},
+	}
+	return json.MarshalIndent(policy, "", "  ")
+}
+
+// SettingsManifest returns the settings of the conversion.
+func SettingsManifest(conversion Conversion) ([]byte, error) {
+	return json.MarshalIndent(conversion.Settings, "", "  ")
+}
+
+// WriteFiles writes the `<name>-settings.json` settings and the
+// `<name>-policy.json` policy manifest of each conversion inside of outputDir,
+// and reports the warnings of the conversions to w.
+func WriteFiles(conversions []Conversion, kind, outputDir, module string, w io.Writer) error {
+	for _, conversion := range conversions {
+		for _, warning := range conversion.Warnings {
+			fmt.Fprintf(w, "%s '%s': %s\n", kind, conversion.Name, warning)
+		}
+
+		settings, err := SettingsManifest(conversion)
+		if err != nil {
+			return err
+		}
+		if err = writeFile(filepath.Join(outputDir, conversion.Name+"-settings.json"), settings); err != nil {
+			return err
+		}
+
+		policy, err := PolicyManifest(conversion, module)
+		if err != nil {
+			return err
+		}
+		if err = writeFile(filepath.Join(outputDir, conversion.Name+"-policy.json"), policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, data []byte) error {
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,226 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+)
+
+type podSecurityPolicy struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec map[string]json.RawMessage `json:"spec"`
+}
+
+type allowedHostPath struct {
+	PathPrefix string `json:"pathPrefix"`
+	ReadOnly   bool   `json:"readOnly"`
+}
+
+type runAsUserStrategy struct {
+	Rule   string `json:"rule"`
+	Ranges []struct {
+		Min int64 `json:"min"`
+		Max int64 `json:"max"`
+	} `json:"ranges"`
+}
+
+// FromPodSecurityPolicies translates all the PodSecurityPolicy objects read
+// from r, either single objects or List ones, into settings.
+func FromPodSecurityPolicies(r io.Reader) ([]Conversion, error) {
+	objects, err := decodeObjects(r, "PodSecurityPolicy")
+	if err != nil {
+		return nil, err
+	}
+
+	conversions := make([]Conversion, 0, len(objects))
+	for _, raw := range objects {
+		conversion, err := fromPodSecurityPolicy(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err = conversion.validate(); err != nil {
+			return nil, err
+		}
+		conversions = append(conversions, conversion)
+	}
+	return conversions, nil
+}
+
+func fromPodSecurityPolicy(raw json.RawMessage) (Conversion, error) {
+	psp := podSecurityPolicy{}
+	if err := json.Unmarshal(raw, &psp); err != nil {
+		return Conversion{}, err
+	}
+
+	conversion := Conversion{
+		Name: psp.Metadata.Name,
+		Settings: hostpaths.Settings{
+			AllowedHostPaths: make([]hostpaths.HostPath, 0),
+		},
+		Warnings: make([]string, 0),
+	}
+
+	if data, found := psp.Spec["allowedHostPaths"]; found {
+		entries := make([]allowedHostPath, 0)
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return Conversion{}, fmt.Errorf("cannot parse allowedHostPaths of '%s': %w", conversion.Name, err)
+		}
+		for _, entry := range entries {
+			conversion.Settings.AllowedHostPaths = appendHostPath(conversion.Settings.AllowedHostPaths, entry)
+			if !entry.ReadOnly {
+				// PSPs accept read-only mounts of writable prefixes,
+				// the policy does not
+				conversion.Warnings = append(conversion.Warnings, fmt.Sprintf(
+					"spec.allowedHostPaths: read-only mounts of '%s' will be rejected, the policy requires them to be writable",
+					entry.PathPrefix))
+			}
+		}
+	}
+
+	if data, found := psp.Spec["volumes"]; found {
+		volumes := make([]string, 0)
+		if err := json.Unmarshal(data, &volumes); err != nil {
+			return Conversion{}, fmt.Errorf("cannot parse volumes of '%s': %w", conversion.Name, err)
+		}
+		if !allowsHostPathVolumes(volumes) {
+			conversion.Warnings = append(conversion.Warnings,
+				"spec.volumes: hostPath volumes are forbidden, the hostpaths-psp policy can only restrict their paths")
+		}
+	}
+
+	privileged := false // the default of PodSecurityPolicies
+	if data, found := psp.Spec["privileged"]; found {
+		if err := json.Unmarshal(data, &privileged); err != nil {
+			return Conversion{}, fmt.Errorf("cannot parse privileged of '%s': %w", conversion.Name, err)
+		}
+	}
+	if !privileged {
+		// the guard rejects the privileged containers of all the pods,
+		// like the PodSecurityPolicy
+		conversion.Settings.PrivilegeGuard = hostpaths.PrivilegeGuardAlways
+	}
+
+	translated := map[string]bool{"allowedHostPaths": true, "volumes": true, "privileged": true}
+	for _, field := range []string{"allowedCapabilities", "defaultAddCapabilities"} {
+		data, found := psp.Spec[field]
+		if !found || privileged {
+			continue
+		}
+		capabilities := make([]string, 0)
+		if err := json.Unmarshal(data, &capabilities); err != nil {
+			return Conversion{}, fmt.Errorf("cannot parse %s of '%s': %w", field, conversion.Name, err)
+		}
+		for _, capability := range rejectedCapabilities(capabilities, conversion.Settings) {
+			conversion.Warnings = append(conversion.Warnings, fmt.Sprintf(
+				"spec.%s: containers adding %s will be rejected by the privilege guard", field, capability))
+			translated[field] = true
+		}
+	}
+	if data, found := psp.Spec["runAsUser"]; found {
+		strategy := runAsUserStrategy{}
+		if err := json.Unmarshal(data, &strategy); err != nil {
+			return Conversion{}, fmt.Errorf("cannot parse runAsUser of '%s': %w", conversion.Name, err)
+		}
+		translated["runAsUser"] = translateRunAsUser(strategy, &conversion)
+	}
+
+	fields := make([]string, 0, len(psp.Spec))
+	for field := range psp.Spec {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if translated[field] || isRunAsAnyRule(psp.Spec[field]) {
+			continue
+		}
+		conversion.Warnings = append(conversion.Warnings,
+			fmt.Sprintf("spec.%s has no equivalent in the hostpaths-psp policy", field))
+	}
+
+	return conversion, nil
+}
+
+// translateRunAsUser requires the identity of the runAsUser strategy from the
+// writable mounts of all the entries, and returns whether the strategy has
+// been translated.
+func translateRunAsUser(strategy runAsUserStrategy, conversion *Conversion) bool {
+	var minRunAsUser, maxRunAsUser int64
+	switch strategy.Rule {
+	case "MustRunAsNonRoot":
+	case "MustRunAs":
+		if len(strategy.Ranges) == 0 {
+			return false
+		}
+		minRunAsUser, maxRunAsUser = strategy.Ranges[0].Min, strategy.Ranges[0].Max
+		for _, userRange := range strategy.Ranges[1:] {
+			minRunAsUser = min(minRunAsUser, userRange.Min)
+			maxRunAsUser = max(maxRunAsUser, userRange.Max)
+		}
+		if len(strategy.Ranges) > 1 {
+			conversion.Warnings = append(conversion.Warnings, fmt.Sprintf(
+				"spec.runAsUser: the ranges are merged into minRunAsUser %d and maxRunAsUser %d",
+				minRunAsUser, maxRunAsUser))
+		}
+	default:
+		return false
+	}
+
+	for i := range conversion.Settings.AllowedHostPaths {
+		hostPath := &conversion.Settings.AllowedHostPaths[i]
+		if strategy.Rule == "MustRunAsNonRoot" {
+			hostPath.RequireRunAsNonRoot = true
+			continue
+		}
+		minimum, maximum := minRunAsUser, maxRunAsUser
+		hostPath.MinRunAsUser, hostPath.MaxRunAsUser = &minimum, &maximum
+	}
+	conversion.Warnings = append(conversion.Warnings,
+		"spec.runAsUser: only required from the containers mounting writable hostPath volumes")
+	return true
+}
+
+// rejectedCapabilities returns the capabilities allowed by the
+// PodSecurityPolicy that the privilege guard of the settings rejects.
+func rejectedCapabilities(capabilities []string, settings hostpaths.Settings) []string {
+	dangerous := settings.DangerousCapabilitiesOrDefault()
+	if slices.Contains(capabilities, "*") {
+		// all the capabilities are allowed
+		return dangerous
+	}
+	rejected := make([]string, 0)
+	for _, capability := range capabilities {
+		normalized := strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+		if slices.Contains(dangerous, normalized) && !slices.Contains(rejected, normalized) {
+			rejected = append(rejected, normalized)
+		}
+	}
+	return rejected
+}
+
+func allowsHostPathVolumes(volumes []string) bool {
+	for _, volume := range volumes {
+		if volume == "*" || volume == "hostPath" {
+			return true
+		}
+	}
+	return false
+}
+
+// isRunAsAnyRule returns whether the field is a strategy placing no
+// restriction, like `runAsUser: {rule: RunAsAny}`.
+func isRunAsAnyRule(data json.RawMessage) bool {
+	strategy := struct {
+		Rule string `json:"rule"`
+	}{}
+	if err := json.Unmarshal(data, &strategy); err != nil {
+		return false
+	}
+	return strategy.Rule == "RunAsAny"
+}
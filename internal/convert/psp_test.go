@@ -0,0 +1,190 @@
+package convert
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+)
+
+func TestFromPodSecurityPolicies(t *testing.T) {
+	for _, tcase := range []struct {
+		name     string
+		testData string
+		expected []Conversion
+	}{
+		{
+			name:     "single object",
+			testData: "../../test_data/psp/psp-restricted.json",
+			expected: []Conversion{
+				{
+					Name: "restricted",
+					Settings: hostpaths.Settings{
+						AllowedHostPaths: []hostpaths.HostPath{
+							{
+								PathPrefix:          "/var/log",
+								ReadOnly:            true,
+								RequireRunAsNonRoot: true,
+							},
+							{
+								PathPrefix:          "/var/lib/agent",
+								ReadOnly:            false,
+								RequireRunAsNonRoot: true,
+							},
+						},
+						PrivilegeGuard: hostpaths.PrivilegeGuardAlways,
+					},
+					Warnings: []string{
+						"spec.allowedHostPaths: read-only mounts of '/var/lib/agent' will be rejected, the policy requires them to be writable",
+						"spec.runAsUser: only required from the containers mounting writable hostPath volumes",
+						"spec.allowPrivilegeEscalation has no equivalent in the hostpaths-psp policy",
+						"spec.hostNetwork has no equivalent in the hostpaths-psp policy",
+						"spec.requiredDropCapabilities has no equivalent in the hostpaths-psp policy",
+					},
+				},
+			},
+		},
+		{
+			name:     "runAsUser ranges",
+			testData: "../../test_data/psp/psp-runasuser-ranges.json",
+			expected: []Conversion{
+				{
+					Name: "agents",
+					Settings: hostpaths.Settings{
+						AllowedHostPaths: []hostpaths.HostPath{
+							{
+								PathPrefix:   "/var/lib/agent",
+								ReadOnly:     false,
+								MinRunAsUser: ptrInt64(1000),
+								MaxRunAsUser: ptrInt64(2999),
+							},
+						},
+					},
+					Warnings: []string{
+						"spec.allowedHostPaths: read-only mounts of '/var/lib/agent' will be rejected, the policy requires them to be writable",
+						"spec.runAsUser: the ranges are merged into minRunAsUser 1000 and maxRunAsUser 2999",
+						"spec.runAsUser: only required from the containers mounting writable hostPath volumes",
+					},
+				},
+			},
+		},
+		{
+			name:     "list",
+			testData: "../../test_data/psp/psp-list.json",
+			expected: []Conversion{
+				{
+					Name: "logging",
+					Settings: hostpaths.Settings{
+						AllowedHostPaths: []hostpaths.HostPath{
+							{
+								PathPrefix: "/var/log",
+								ReadOnly:   true,
+							},
+						},
+						PrivilegeGuard: hostpaths.PrivilegeGuardAlways,
+					},
+					Warnings: []string{
+						"spec.allowedCapabilities: containers adding SYS_ADMIN will be rejected by the privilege guard",
+					},
+				},
+				{
+					Name: "no-host-paths",
+					Settings: hostpaths.Settings{
+						AllowedHostPaths: []hostpaths.HostPath{},
+						PrivilegeGuard:   hostpaths.PrivilegeGuardAlways,
+					},
+					Warnings: []string{
+						"spec.volumes: hostPath volumes are forbidden, the hostpaths-psp policy can only restrict their paths",
+					},
+				},
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			f, err := os.Open(tcase.testData)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+			defer f.Close()
+
+			conversions, err := FromPodSecurityPolicies(f)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			if !reflect.DeepEqual(conversions, tcase.expected) {
+				t.Errorf("Wanted %+v, but got %+v instead", tcase.expected, conversions)
+			}
+		})
+	}
+}
+
+func TestFromPodSecurityPoliciesWithUnexpectedKind(t *testing.T) {
+	_, err := FromPodSecurityPolicies(strings.NewReader(`{"apiVersion": "v1", "kind": "Pod"}`))
+	expected := "unexpected object of kind 'Pod', expected PodSecurityPolicy or List"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Wanted error '%s', but got '%v' instead", expected, err)
+	}
+}
+
+func TestPolicyManifest(t *testing.T) {
+	conversion := Conversion{
+		Name: "restricted",
+		Settings: hostpaths.Settings{
+			AllowedHostPaths: []hostpaths.HostPath{
+				{
+					PathPrefix: "/var/log",
+					ReadOnly:   true,
+				},
+			},
+		},
+	}
+
+	manifest, err := PolicyManifest(conversion, DefaultModule)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	policy := clusterAdmissionPolicy{}
+	if err = json.Unmarshal(manifest, &policy); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if policy.Kind != "ClusterAdmissionPolicy" ||
+		policy.Metadata.Name != "hostpaths-psp-restricted" ||
+		policy.Spec.Module != DefaultModule ||
+		!reflect.DeepEqual(policy.Spec.Settings, conversion.Settings) {
+		t.Errorf("Unexpected policy manifest %s", manifest)
+	}
+}
+
+func ptrInt64(i int64) *int64 {
+	return &i
+}
+
+func TestFromPodSecurityPoliciesWithSamePathPrefix(t *testing.T) {
+	conversions, err := FromPodSecurityPolicies(strings.NewReader(`{
+		"apiVersion": "policy/v1beta1",
+		"kind": "PodSecurityPolicy",
+		"metadata": {"name": "duplicates"},
+		"spec": {
+			"allowedHostPaths": [
+				{"pathPrefix": "/var/log", "readOnly": true},
+				{"pathPrefix": "/data", "readOnly": true},
+				{"pathPrefix": "/var/log/", "readOnly": false}
+			]
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	expected := []hostpaths.HostPath{
+		{PathPrefix: "/var/log", ReadOnly: false},
+		{PathPrefix: "/data", ReadOnly: true},
+	}
+	if len(conversions) != 1 || !reflect.DeepEqual(conversions[0].Settings.AllowedHostPaths, expected) {
+		t.Errorf("Wanted %+v, but got %+v instead", expected, conversions)
+	}
+}
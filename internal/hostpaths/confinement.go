@@ -0,0 +1,109 @@
+package hostpaths
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RuleConfinement is violated by the mounts of containers not running with
+// the SELinux type or AppArmor profile required by their AllowedHostPaths
+// entry.
+const RuleConfinement = "hostpath-confinement"
+
+// appArmorAnnotationPrefix prefixes the name of the container inside of the
+// deprecated AppArmor annotations of the pods.
+const appArmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+
+type seLinuxOptions struct {
+	Type string `json:"type"`
+}
+
+type appArmorProfile struct {
+	Type             string `json:"type"`
+	LocalhostProfile string `json:"localhostProfile"`
+}
+
+// name returns the name of the profile, using the syntax of the deprecated
+// AppArmor annotations: `runtime/default`, `localhost/<profile>` or
+// `unconfined`.
+func (p *appArmorProfile) name() string {
+	switch p.Type {
+	case "RuntimeDefault":
+		return "runtime/default"
+	case "Localhost":
+		return "localhost/" + p.LocalhostProfile
+	case "Unconfined":
+		return "unconfined"
+	default:
+		return p.Type
+	}
+}
+
+func (h HostPath) hasConfinementRequirements() bool {
+	return len(h.SELinuxTypes) > 0 || len(h.AppArmorProfiles) > 0
+}
+
+// validateConfinement returns the messages of the confinement requirements
+// of the given AllowedHostPaths entry not met by the mount. The annotations
+// are the ones of the pod, see appArmorProfileName.
+func (s *identitySpec) validateConfinement(mount Mount, hostPath HostPath, annotations map[string]string) []string {
+	messages := make([]string, 0)
+	effective := s.effectiveContext(mount.Container)
+	newMessage := func(what string, allowed []string, actual string) string {
+		message := fmt.Sprintf("%s mounted as '%s' requires container '%s' to run with the %s %s",
+			mount.Volume, mount.Name, mount.Container, what, quoteList(allowed))
+		if actual == "" {
+			return message + ", it is not set"
+		}
+		return message + fmt.Sprintf(", not '%s'", actual)
+	}
+
+	if len(hostPath.SELinuxTypes) > 0 {
+		actual := ""
+		if effective.SELinuxOptions != nil {
+			actual = effective.SELinuxOptions.Type
+		}
+		if !slices.Contains(hostPath.SELinuxTypes, actual) {
+			messages = append(messages, newMessage("SELinux type", hostPath.SELinuxTypes, actual))
+		}
+	}
+	if len(hostPath.AppArmorProfiles) > 0 {
+		actual := s.appArmorProfileName(mount.Container, annotations)
+		if !slices.Contains(hostPath.AppArmorProfiles, actual) {
+			messages = append(messages, newMessage("AppArmor profile", hostPath.AppArmorProfiles, actual))
+		}
+	}
+	return messages
+}
+
+// appArmorProfileName returns the name of the AppArmor profile the given
+// container runs with. Like the kubelet does, the field of the container takes
+// precedence over its deprecated annotation, which takes precedence over the
+// field of the pod.
+func (s *identitySpec) appArmorProfileName(containerName string, annotations map[string]string) string {
+	for _, containers := range [][]identityContainer{s.InitContainers, s.Containers, s.EphemeralContainers} {
+		for _, container := range containers {
+			if container.Name == containerName && container.SecurityContext != nil &&
+				container.SecurityContext.AppArmorProfile != nil {
+				return container.SecurityContext.AppArmorProfile.name()
+			}
+		}
+	}
+	if profile, found := annotations[appArmorAnnotationPrefix+containerName]; found {
+		return profile
+	}
+	if s.SecurityContext != nil && s.SecurityContext.AppArmorProfile != nil {
+		return s.SecurityContext.AppArmorProfile.name()
+	}
+	return ""
+}
+
+// quoteList returns the quoted values separated by "or".
+func quoteList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, "'"+value+"'")
+	}
+	return strings.Join(quoted, " or ")
+}
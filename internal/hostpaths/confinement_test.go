@@ -0,0 +1,131 @@
+package hostpaths
+
+import (
+	"reflect"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestValidateMountsConfinementRequirements(t *testing.T) {
+	seLinux := HostPath{PathPrefix: "/var/log", ReadOnly: true, SELinuxTypes: []string{"logreader_t", "spc_t"}}
+	appArmor := HostPath{PathPrefix: "/var/log", ReadOnly: true, AppArmorProfiles: []string{"runtime/default", "localhost/logs"}}
+
+	for _, tcase := range []struct {
+		name     string
+		hostPath HostPath
+		metadata string
+		spec     string
+		messages []string
+	}{
+		{
+			name:     "SELinux type of the pod",
+			hostPath: seLinux,
+			spec:     `{"securityContext": {"seLinuxOptions": {"type": "logreader_t"}}}`,
+			messages: []string{},
+		},
+		{
+			name:     "SELinux type overridden by the container",
+			hostPath: seLinux,
+			spec: `{
+				"securityContext": {"seLinuxOptions": {"type": "logreader_t"}},
+				"containers": [{"name": "app", "securityContext": {"seLinuxOptions": {"type": "container_t"}}}]
+			}`,
+			messages: []string{
+				"hostPath '/var/log' mounted as 'logs' requires container 'app' to run with the SELinux type 'logreader_t' or 'spc_t', not 'container_t'",
+			},
+		},
+		{
+			name:     "SELinux type not set",
+			hostPath: seLinux,
+			spec:     `{}`,
+			messages: []string{
+				"hostPath '/var/log' mounted as 'logs' requires container 'app' to run with the SELinux type 'logreader_t' or 'spc_t', it is not set",
+			},
+		},
+		{
+			name:     "AppArmor localhost profile of the container",
+			hostPath: appArmor,
+			spec: `{
+				"securityContext": {"appArmorProfile": {"type": "Unconfined"}},
+				"containers": [{"name": "app", "securityContext": {"appArmorProfile": {"type": "Localhost", "localhostProfile": "logs"}}}]
+			}`,
+			messages: []string{},
+		},
+		{
+			name:     "AppArmor unconfined",
+			hostPath: appArmor,
+			spec:     `{"securityContext": {"appArmorProfile": {"type": "Unconfined"}}}`,
+			messages: []string{
+				"hostPath '/var/log' mounted as 'logs' requires container 'app' to run with the AppArmor profile 'runtime/default' or 'localhost/logs', not 'unconfined'",
+			},
+		},
+		{
+			name:     "AppArmor annotation of the container",
+			hostPath: appArmor,
+			metadata: `{"annotations": {"container.apparmor.security.beta.kubernetes.io/app": "localhost/logs"}}`,
+			spec:     `{"securityContext": {"appArmorProfile": {"type": "Unconfined"}}}`,
+			messages: []string{},
+		},
+		{
+			name:     "AppArmor annotation overridden by the field of the container",
+			hostPath: appArmor,
+			metadata: `{"annotations": {"container.apparmor.security.beta.kubernetes.io/app": "runtime/default"}}`,
+			spec:     `{"containers": [{"name": "app", "securityContext": {"appArmorProfile": {"type": "Unconfined"}}}]}`,
+			messages: []string{
+				"hostPath '/var/log' mounted as 'logs' requires container 'app' to run with the AppArmor profile 'runtime/default' or 'localhost/logs', not 'unconfined'",
+			},
+		},
+		{
+			name:     "AppArmor annotation of another container",
+			hostPath: appArmor,
+			metadata: `{"annotations": {"container.apparmor.security.beta.kubernetes.io/sidecar": "runtime/default"}}`,
+			spec:     `{}`,
+			messages: []string{
+				"hostPath '/var/log' mounted as 'logs' requires container 'app' to run with the AppArmor profile 'runtime/default' or 'localhost/logs', it is not set",
+			},
+		},
+		{
+			name: "both required",
+			hostPath: HostPath{
+				PathPrefix:       "/var/log",
+				ReadOnly:         true,
+				SELinuxTypes:     []string{"logreader_t"},
+				AppArmorProfiles: []string{"runtime/default"},
+			},
+			spec: `{"securityContext": {"seLinuxOptions": {"type": "logreader_t"}}}`,
+			messages: []string{
+				"hostPath '/var/log' mounted as 'logs' requires container 'app' to run with the AppArmor profile 'runtime/default', it is not set",
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			metadata := tcase.metadata
+			if metadata == "" {
+				metadata = `{}`
+			}
+			object := []byte(`{"metadata": ` + metadata + `, "spec": ` + tcase.spec + `}`)
+			gvk := kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"}
+			settings := Settings{AllowedHostPaths: []HostPath{tcase.hostPath}}
+			podSpecs, err := ExtractPodSpecs(gvk, object, settings)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			mounts := []Mount{
+				{Volume: Volume{Name: "logs", Path: "/var/log"}, Container: "app", Name: "logs", ReadOnly: true},
+			}
+			violations := ValidateMounts(podSpecs[0], mounts, settings)
+			messages := make([]string, 0, len(violations))
+			for _, violation := range violations {
+				messages = append(messages, violation.Message)
+				if violation.Rule != RuleConfinement {
+					t.Errorf("Unexpected rule %s", violation.Rule)
+				}
+			}
+			if !reflect.DeepEqual(messages, tcase.messages) {
+				t.Errorf("Wanted %q, but got %q instead", tcase.messages, messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,753 @@
+package hostpaths
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/kubewarden/gjson"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// EmbeddedPodSpec is a pod spec found inside of an object.
+type EmbeddedPodSpec struct {
+	// Path is the gjson path of the pod spec inside of the object
+	Path string
+	// Implicit is true when the path is implied by the kind of the
+	// object, like `spec.template.spec` for Deployments. Implicit paths
+	// are not reported by the violations
+	Implicit bool
+	Spec     corev1.PodSpec
+	// Raw is the JSON document of the pod spec, to read the fields whose
+	// unset and zero values differ, like `runAsUser`
+	Raw []byte
+	// Kind is the kind of the object embedding the pod spec, and
+	// OwnerKind the kind of the controller owning it, for Pods
+	Kind      string
+	OwnerKind string
+	// Labels and Annotations are the ones of the pod, or of the pod
+	// template, embedding the pod spec
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// ExtractPodSpecs returns all the pod specs embedded inside of the given
+// object. The kinds with pod spec paths, either builtin or defined inside of
+// the settings, can embed any number of pod specs; Pods and the workload
+// resources embed exactly one.
+func ExtractPodSpecs(gvk kubewarden_protocol.GroupVersionKind, object []byte, settings Settings) ([]EmbeddedPodSpec, error) {
+	podSpecs, err := extractPodSpecs(gvk, object, settings)
+	if err != nil {
+		return nil, err
+	}
+	ownerKind := ""
+	if gvk.Group == "" && gvk.Kind == "Pod" {
+		ownerKind = gjson.GetBytes(object, "metadata.ownerReferences.#(controller==true).kind").String()
+	}
+	for i := range podSpecs {
+		podSpecs[i].Kind = gvk.Kind
+		podSpecs[i].OwnerKind = ownerKind
+	}
+	return podSpecs, nil
+}
+
+func extractPodSpecs(gvk kubewarden_protocol.GroupVersionKind, object []byte, settings Settings) ([]EmbeddedPodSpec, error) {
+	paths := settings.PodSpecPathsFor(gvk)
+	if len(paths) == 0 {
+		path, found := workloadPodSpecPath(gvk.Kind)
+		if !found {
+			return nil, errors.New("object should be one of these kinds: " +
+				"Deployment, ReplicaSet, StatefulSet, DaemonSet, ReplicationController, Job, CronJob, Pod")
+		}
+		data := gjson.GetBytes(object, path)
+		if !data.Exists() {
+			// the object has no pod spec yet, nothing to validate
+			return []EmbeddedPodSpec{}, nil
+		}
+		podSpec, err := newEmbeddedPodSpec(path, data)
+		if err != nil {
+			return nil, err
+		}
+		podSpec.Implicit = true
+		podSpec.Labels = podTemplateMetadata(object, path, "labels")
+		podSpec.Annotations = podTemplateMetadata(object, path, "annotations")
+		return []EmbeddedPodSpec{podSpec}, nil
+	}
+
+	podSpecs := make([]EmbeddedPodSpec, 0)
+	for _, path := range paths {
+		data := gjson.GetBytes(object, path)
+		if !data.Exists() {
+			// the object doesn't embed a pod spec at this path, skip
+			continue
+		}
+		if !data.IsArray() {
+			podSpec, err := newEmbeddedPodSpec(path, data)
+			if err != nil {
+				return nil, err
+			}
+			podSpec.Labels = podTemplateMetadata(object, path, "labels")
+			podSpec.Annotations = podTemplateMetadata(object, path, "annotations")
+			podSpecs = append(podSpecs, podSpec)
+			continue
+		}
+		// path uses a gjson query returning many pod specs
+		for i, item := range data.Array() {
+			podSpec, err := newEmbeddedPodSpec(fmt.Sprintf("%s[%d]", path, i), item)
+			if err != nil {
+				return nil, err
+			}
+			podSpecs = append(podSpecs, podSpec)
+		}
+	}
+	return podSpecs, nil
+}
+
+// HasPodSpecs returns whether the objects of the given kind embed pod specs
+// validated by the policy.
+func HasPodSpecs(gvk kubewarden_protocol.GroupVersionKind, settings Settings) bool {
+	if len(settings.PodSpecPathsFor(gvk)) > 0 {
+		return true
+	}
+	_, found := workloadPodSpecPath(gvk.Kind)
+	return found
+}
+
+// workloadPodSpecPath returns the path of the pod spec embedded inside of
+// Pods and the workload resources.
+func workloadPodSpecPath(kind string) (string, bool) {
+	switch kind {
+	case "Pod":
+		return "spec", true
+	case "Deployment", "ReplicaSet", "StatefulSet", "DaemonSet", "ReplicationController", "Job":
+		return "spec.template.spec", true
+	case "CronJob":
+		return "spec.jobTemplate.spec.template.spec", true
+	default:
+		return "", false
+	}
+}
+
+// podTemplateMetadata returns the labels or the annotations, depending on the
+// given field, of the pod template embedding the pod spec found at the given
+// path, read from the `metadata` field next to the `spec` one. The pod specs
+// found at other paths have neither.
+func podTemplateMetadata(object []byte, path, field string) map[string]string {
+	var metadataPath string
+	switch {
+	case path == "spec":
+		metadataPath = "metadata." + field
+	case strings.HasSuffix(path, ".spec"):
+		metadataPath = strings.TrimSuffix(path, "spec") + "metadata." + field
+	default:
+		return nil
+	}
+	values := make(map[string]string)
+	gjson.GetBytes(object, metadataPath).ForEach(func(key, value gjson.Result) bool {
+		values[key.String()] = value.String()
+		return true
+	})
+	return values
+}
+
+func newEmbeddedPodSpec(path string, data gjson.Result) (EmbeddedPodSpec, error) {
+	podSpec := corev1.PodSpec{}
+	if err := json.Unmarshal([]byte(data.Raw), &podSpec); err != nil {
+		return EmbeddedPodSpec{}, fmt.Errorf("cannot parse pod spec at '%s': %w", path, err)
+	}
+	return EmbeddedPodSpec{Path: path, Spec: podSpec, Raw: []byte(data.Raw)}, nil
+}
+
+// Volume is a volume giving access to a path of the node.
+type Volume struct {
+	Name string
+	Path string
+	// PersistentVolume and Claim are set when the path belongs to a
+	// PersistentVolume bound to a PersistentVolumeClaim used by the pod
+	PersistentVolume string
+	Claim            string
+	// ReadOnly is true when the volume source forces all its mounts to be
+	// read-only
+	ReadOnly bool
+}
+
+func (v Volume) String() string {
+	if v.PersistentVolume != "" {
+		return fmt.Sprintf("hostPath '%s' of PersistentVolume '%s' bound to claim '%s'",
+			v.Path, v.PersistentVolume, v.Claim)
+	}
+	return fmt.Sprintf("hostPath '%s'", v.Path)
+}
+
+// HostPathVolumes returns the hostPath volumes defined inside of the given
+// pod spec.
+func HostPathVolumes(podSpec corev1.PodSpec) []Volume {
+	volumes := make([]Volume, 0)
+	for _, volume := range podSpec.Volumes {
+		if volume.HostPath != nil {
+			volumes = append(volumes, Volume{
+				Name: *volume.Name,
+				Path: *volume.HostPath.Path,
+			})
+		}
+	}
+	return volumes
+}
+
+// Mount is a Volume mounted by a container.
+type Mount struct {
+	Volume    Volume
+	Container string
+	// Image is the image of the container
+	Image string
+	// Role is the role of the container, like RoleInit
+	Role     string
+	Name     string
+	ReadOnly bool
+}
+
+// Mounts returns all the mounts of the given volumes done by the init,
+// regular and ephemeral containers of the pod spec.
+func Mounts(podSpec corev1.PodSpec, volumes []Volume) []Mount {
+	mounts := make([]Mount, 0)
+	for _, volume := range volumes {
+		for i, container := range Containers(podSpec) {
+			containerName := ContainerName(container)
+			for _, mount := range container.VolumeMounts {
+				if volume.Name != *mount.Name {
+					// volume and mount don't match, skip
+					continue
+				}
+				mounts = append(mounts, Mount{
+					Volume:    volume,
+					Container: containerName,
+					Image:     container.Image,
+					Role:      ContainerRole(podSpec, i),
+					Name:      *mount.Name,
+					ReadOnly:  mount.ReadOnly || volume.ReadOnly,
+				})
+			}
+		}
+	}
+	return mounts
+}
+
+// Containers returns the init, regular and ephemeral containers of the given
+// pod spec. The ephemeral containers are converted to containers holding the
+// fields inspected by the policy.
+func Containers(podSpec corev1.PodSpec) []*corev1.Container {
+	containers := make([]*corev1.Container, 0)
+	containers = append(containers, podSpec.InitContainers...)
+	containers = append(containers, podSpec.Containers...)
+	for _, ephemeralContainer := range podSpec.EphemeralContainers {
+		containers = append(containers, &corev1.Container{
+			Name:            ephemeralContainer.Name,
+			Image:           ephemeralContainer.Image,
+			VolumeMounts:    ephemeralContainer.VolumeMounts,
+			SecurityContext: ephemeralContainer.SecurityContext,
+		})
+	}
+	return containers
+}
+
+// Roles of the containers, see ContainerRole.
+const (
+	// RoleInit is the role of the init containers, running to completion
+	// before the other ones
+	RoleInit = "init"
+	// RoleSidecar is the role of the native sidecars: init containers with
+	// the `Always` restartPolicy, running next to the regular containers
+	RoleSidecar = "sidecar"
+	// RoleContainer is the role of the regular containers
+	RoleContainer = "container"
+	// RoleEphemeral is the role of the ephemeral containers
+	RoleEphemeral = "ephemeral"
+)
+
+// ContainerRole returns the role of the container found at the given index of
+// the list returned by Containers.
+func ContainerRole(podSpec corev1.PodSpec, index int) string {
+	switch {
+	case index < len(podSpec.InitContainers):
+		if podSpec.InitContainers[index].RestartPolicy == "Always" {
+			return RoleSidecar
+		}
+		return RoleInit
+	case index < len(podSpec.InitContainers)+len(podSpec.Containers):
+		return RoleContainer
+	default:
+		return RoleEphemeral
+	}
+}
+
+// ContainerName returns the name of the given container, empty when unset.
+func ContainerName(container *corev1.Container) string {
+	if container.Name == nil {
+		return ""
+	}
+	return *container.Name
+}
+
+// Rules identifying the kind of the violations.
+const (
+	// RuleNotAllowed is violated by the paths outside of the
+	// AllowedHostPaths list
+	RuleNotAllowed = "hostpath-not-allowed"
+	// RuleKindNotAllowed is violated by the paths whose AllowedHostPaths
+	// entries are restricted to other kinds
+	RuleKindNotAllowed = "hostpath-kind-not-allowed"
+	// RuleRuntimeClassNotAllowed is violated by the paths whose
+	// AllowedHostPaths entries are restricted to other RuntimeClasses
+	RuleRuntimeClassNotAllowed = "hostpath-runtimeclass-not-allowed"
+	// RuleReadOnly is violated by the mounts whose readOnly attribute
+	// doesn't match the one of their AllowedHostPaths entry
+	RuleReadOnly = "hostpath-readonly"
+	// RuleTypeNotAllowed is violated by the hostPath PersistentVolumes whose
+	// type is outside of the PersistentVolumeHostPathTypes list
+	RuleTypeNotAllowed = "hostpath-type-not-allowed"
+)
+
+// Violation is a hostPath usage rejected by the policy.
+type Violation struct {
+	// Rule is the identifier of the violated rule, like RuleNotAllowed
+	Rule string
+	// PodSpec is the pod spec holding the offending mount, empty for
+	// PersistentVolume objects
+	PodSpec string
+	// implicit is true when PodSpec is implied by the kind of the object
+	implicit bool
+	Volume   Volume
+	// Container and Mount are empty for PersistentVolume objects
+	Container string
+	Mount     string
+	Message   string
+}
+
+// Error returns the message of the violation, prefixed by the path of its pod
+// spec when it cannot be deduced from the kind of the object.
+func (v Violation) Error() string {
+	if v.PodSpec != "" && !v.implicit {
+		return v.PodSpec + ": " + v.Message
+	}
+	return v.Message
+}
+
+// Evaluate validates the hostPath volumes of the given pod spec against the
+// settings, and returns all the violations found, followed by the ones of the
+// privilege guard unless they are only logged as warnings.
+func Evaluate(podSpec EmbeddedPodSpec, settings Settings) []Violation {
+	mounts := Mounts(podSpec.Spec, HostPathVolumes(podSpec.Spec))
+	violations := ValidateMounts(podSpec, mounts, settings)
+	if !settings.PrivilegeGuardWarnOnly {
+		violations = append(violations, EvaluatePrivileges(podSpec, len(mounts) > 0, settings)...)
+	}
+	return violations
+}
+
+// ValidateMounts validates the given mounts of a pod spec against the
+// AllowedHostPaths list, and returns all the violations found. An empty list
+// allows all the mounts.
+func ValidateMounts(podSpec EmbeddedPodSpec, mounts []Mount, settings Settings) []Violation {
+	violations := make([]Violation, 0)
+	if len(settings.AllowedHostPaths) == 0 {
+		return violations
+	}
+	var identity *identitySpec // parsed when needed
+	for _, mount := range mounts {
+		newViolation := func(rule, message string) Violation {
+			return Violation{
+				Rule:      rule,
+				PodSpec:   podSpec.Path,
+				implicit:  podSpec.Implicit,
+				Volume:    mount.Volume,
+				Container: mount.Container,
+				Mount:     mount.Name,
+				Message:   message,
+			}
+		}
+
+		match := false
+		var violationsMount []Violation // all violations of current mount
+		// a writable mount of an ancestor would give write access to the
+		// read-only paths below it
+		protected, protect := "", false
+		if settings.ProtectReadOnlySubPaths {
+			protected, protect = readOnlySubPath(podSpec, mount, settings)
+		}
+		// readOnly attribute of most specific AllowedHostPath takes precendence:
+		previousAllowedHostPath := ""
+		var governing HostPath
+		// most specific entry refusing the kind, the RuntimeClass or the
+		// image, and why
+		var refused *HostPath
+		var refusal, refusalRule string
+		for _, allowedHostPath := range settings.AllowedHostPaths {
+			if allowedHostPath.Selector != nil && !allowedHostPath.Selector.Matches(podSpec.Labels) {
+				// the entry doesn't apply to the pod, it takes no part
+				// in the precedence
+				continue
+			}
+			if HasPathPrefix(mount.Volume.Path, allowedHostPath.PathPrefix) {
+				// the entry doesn't apply to the kind of the object, to
+				// the RuntimeClass of the pod or to the image of the
+				// container
+				if rule, reason := allowedHostPath.refusal(podSpec, mount); rule != "" {
+					if refused == nil || len(allowedHostPath.PathPrefix) > len(refused.PathPrefix) {
+						refused, refusal, refusalRule = &allowedHostPath, reason, rule
+					}
+					continue
+				}
+			}
+			if HasPathPrefix(mount.Volume.Path, allowedHostPath.PathPrefix) {
+				// current setting allowedHostPath matches path of volumeMount
+				if HasPathPrefix(allowedHostPath.PathPrefix, previousAllowedHostPath) &&
+					!(match && governing.overrides(allowedHostPath)) {
+					// allowedHostPath is more specific (and has precendence over
+					//	past allowedHostPath), or the same path and not
+					//	overridden by a restricted entry
+					match = true
+					readOnly, byRole := allowedHostPath.readOnlyFor(mount.Role)
+					reason, mountedBy := "", ""
+					if byRole {
+						// the attribute of the role differs from the
+						// one of the other containers, name it
+						mountedBy = fmt.Sprintf(" by %s container '%s'", mount.Role, mount.Container)
+					}
+					if protect && !readOnly {
+						readOnly = true
+						reason = fmt.Sprintf(", it contains the read-only allowed path '%s'", protected)
+					}
+					if mount.ReadOnly == readOnly {
+						// drop violations in violationsMount, we found a
+						// more specific path that validates the current
+						// mount
+						violationsMount = nil
+					} else {
+						// we found even more violations for this specific
+						// mount, append
+						violationsMount = append(violationsMount, newViolation(RuleReadOnly, fmt.Sprintf(
+							"%s mounted as '%s'%s should be readOnly '%t'%s",
+							mount.Volume, mount.Name, mountedBy, readOnly, reason)))
+					}
+					previousAllowedHostPath = allowedHostPath.PathPrefix
+					governing = allowedHostPath
+				}
+			}
+		}
+		if match && len(violationsMount) == 0 &&
+			(governing.hasIdentityRequirements() || governing.hasConfinementRequirements()) {
+			if identity == nil {
+				identity = newIdentitySpec(podSpec.Raw)
+			}
+			if !mount.ReadOnly {
+				for _, message := range identity.validate(mount, governing) {
+					violationsMount = append(violationsMount, newViolation(RuleIdentity, message))
+				}
+			}
+			for _, message := range identity.validateConfinement(mount, governing, podSpec.Annotations) {
+				violationsMount = append(violationsMount, newViolation(RuleConfinement, message))
+			}
+		}
+		if match && len(violationsMount) == 0 {
+			for _, message := range validateNodePinning(podSpec.Spec, mount, governing) {
+				violationsMount = append(violationsMount, newViolation(RuleNodePinning, message))
+			}
+		}
+		// concat to all violations:
+		violations = append(violations, violationsMount...)
+		if !match && refused != nil {
+			// path only matched entries restricted to other kinds,
+			// RuntimeClasses or images
+			violations = append(violations, newViolation(refusalRule, fmt.Sprintf(
+				"%s mounted as '%s' is not allowed for %s",
+				mount.Volume, mount.Name, refusal)))
+		} else if !match {
+			// path didn't match against any PathPrefix in settings
+			violations = append(violations, newViolation(RuleNotAllowed, fmt.Sprintf(
+				"%s mounted as '%s' is not in the AllowedHostPaths list",
+				mount.Volume, mount.Name)))
+		}
+	}
+	return violations
+}
+
+// readOnlyFor returns the readOnly attribute required for the mounts of the
+// containers with the given role, and whether it is the own attribute of the
+// role. The roles without their own attribute use ReadOnly, like the regular
+// containers.
+func (h HostPath) readOnlyFor(role string) (bool, bool) {
+	var readOnly *bool
+	switch role {
+	case RoleInit:
+		readOnly = h.InitContainersReadOnly
+	case RoleSidecar:
+		readOnly = h.SidecarContainersReadOnly
+	case RoleEphemeral:
+		readOnly = h.EphemeralContainersReadOnly
+	}
+	if readOnly == nil {
+		return h.ReadOnly, false
+	}
+	return *readOnly, true
+}
+
+// refusal returns the rule and the reason why the entry doesn't apply to the
+// mount, because of the kind of the object, the RuntimeClass of the pod or the
+// image of the container.
+// The rule is empty when the entry applies.
+func (h HostPath) refusal(podSpec EmbeddedPodSpec, mount Mount) (string, string) {
+	if !h.allowsKind(podSpec) {
+		return RuleKindNotAllowed, fmt.Sprintf("%s, only for %s",
+			podSpec.kindDescription(), quoteList(h.AllowedKinds))
+	}
+	if !h.allowsRuntimeClass(podSpec.Spec.RuntimeClassName) {
+		return RuleRuntimeClassNotAllowed, fmt.Sprintf("%s, only for %s",
+			runtimeClassDescription(podSpec.Spec.RuntimeClassName), quoteList(h.AllowedRuntimeClasses))
+	}
+	if !h.allowsImage(mount.Image) {
+		return RuleImageNotAllowed, fmt.Sprintf("image '%s' of container '%s', only for %s",
+			mount.Image, mount.Container, quoteList(h.AllowedImages))
+	}
+	return "", ""
+}
+
+// allowsRuntimeClass returns whether the entry applies to the pods running
+// with the given RuntimeClass, empty for the default one.
+func (h HostPath) allowsRuntimeClass(runtimeClassName string) bool {
+	return len(h.AllowedRuntimeClasses) == 0 || slices.Contains(h.AllowedRuntimeClasses, runtimeClassName)
+}
+
+// runtimeClassDescription describes the RuntimeClass of the pod.
+func runtimeClassDescription(runtimeClassName string) string {
+	if runtimeClassName == "" {
+		return "the default runtimeClassName"
+	}
+	return fmt.Sprintf("runtimeClassName '%s'", runtimeClassName)
+}
+
+// allowsImage returns whether the entry applies to the containers running
+// the given image.
+func (h HostPath) allowsImage(image string) bool {
+	if len(h.AllowedImages) == 0 {
+		return true
+	}
+	for _, pattern := range h.AllowedImages {
+		if matchesImage(pattern, image) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsKind returns whether the entry applies to the kind of the object
+// embedding the pod spec. Pods are matched by the kind of their controller
+// too, so that the pods of a DaemonSet get the entries of DaemonSets.
+func (h HostPath) allowsKind(podSpec EmbeddedPodSpec) bool {
+	if len(h.AllowedKinds) == 0 {
+		return true
+	}
+	return slices.Contains(h.AllowedKinds, podSpec.Kind) ||
+		(podSpec.OwnerKind != "" && slices.Contains(h.AllowedKinds, podSpec.OwnerKind))
+}
+
+// kindDescription describes the kind of the object embedding the pod spec.
+func (p EmbeddedPodSpec) kindDescription() string {
+	if p.OwnerKind != "" {
+		return fmt.Sprintf("kind '%s' owned by a '%s'", p.Kind, p.OwnerKind)
+	}
+	return fmt.Sprintf("kind '%s'", p.Kind)
+}
+
+// readOnlySubPath returns the first AllowedHostPaths entry below the path of
+// the mount that requires it to be read-only, if any. Like in ValidateMounts,
+// the entries not applying to the pod, its kind, RuntimeClass or to the image
+// of the container are skipped, as well as the ones overridden by a restricted
+// entry, and the readOnly attribute is the one of the role of the container.
+func readOnlySubPath(podSpec EmbeddedPodSpec, mount Mount, settings Settings) (string, bool) {
+	path := mount.Volume.Path
+	applying := make([]HostPath, 0)
+	for _, allowedHostPath := range settings.AllowedHostPaths {
+		if !HasPathPrefix(allowedHostPath.PathPrefix, path) || HasPathPrefix(path, allowedHostPath.PathPrefix) {
+			continue
+		}
+		if allowedHostPath.Selector != nil && !allowedHostPath.Selector.Matches(podSpec.Labels) {
+			continue
+		}
+		if rule, _ := allowedHostPath.refusal(podSpec, mount); rule != "" {
+			continue
+		}
+		applying = append(applying, allowedHostPath)
+	}
+	for _, allowedHostPath := range applying {
+		if slices.ContainsFunc(applying, func(other HostPath) bool { return other.overrides(allowedHostPath) }) {
+			continue
+		}
+		if readOnly, _ := allowedHostPath.readOnlyFor(mount.Role); readOnly {
+			return allowedHostPath.PathPrefix, true
+		}
+	}
+	return "", false
+}
+
+// PersistentVolumePath returns the path of the node used by the given
+// PersistentVolume, if any.
+func PersistentVolumePath(persistentVolume corev1.PersistentVolume) string {
+	if persistentVolume.Spec == nil {
+		return ""
+	}
+	if persistentVolume.Spec.HostPath != nil && persistentVolume.Spec.HostPath.Path != nil {
+		return *persistentVolume.Spec.HostPath.Path
+	}
+	if persistentVolume.Spec.Local != nil && persistentVolume.Spec.Local.Path != nil {
+		return *persistentVolume.Spec.Local.Path
+	}
+	return ""
+}
+
+// EvaluatePersistentVolume validates the `hostPath` or `local` path of the
+// given PersistentVolume, it must be inside of the AllowedHostPaths list.
+// Only the entries applying to all the workloads are considered, see
+// HostPath.workloadScoped: the pods using the volume through a claim are not
+// known yet. The type of `hostPath` volumes must be inside of the
+// PersistentVolumeHostPathTypes list, when set.
+// The readOnly attribute of the AllowedHostPaths is not enforced here:
+// whether the volume is mounted read-only is decided by the pods using it.
+func EvaluatePersistentVolume(name string, persistentVolume corev1.PersistentVolume, settings Settings) []Violation {
+	path := PersistentVolumePath(persistentVolume)
+	if path == "" {
+		// not backed by a path of the node
+		return []Violation{}
+	}
+	volume := Volume{
+		Path:             path,
+		PersistentVolume: name,
+	}
+
+	violations := make([]Violation, 0)
+	if hostPathType, ok := persistentVolumeHostPathType(persistentVolume); ok &&
+		len(settings.PersistentVolumeHostPathTypes) > 0 &&
+		!slices.Contains(settings.PersistentVolumeHostPathTypes, hostPathType) {
+		violations = append(violations, Violation{
+			Rule:   RuleTypeNotAllowed,
+			Volume: volume,
+			Message: fmt.Sprintf("hostPath '%s' of PersistentVolume '%s' has type '%s' instead of %s",
+				path, name, hostPathType, quoteList(settings.PersistentVolumeHostPathTypes)),
+		})
+	}
+	if len(settings.AllowedHostPaths) == 0 {
+		// all the paths allowed
+		return violations
+	}
+
+	scoped := false
+	for _, allowedHostPath := range settings.AllowedHostPaths {
+		if !HasPathPrefix(path, allowedHostPath.PathPrefix) {
+			continue
+		}
+		if !allowedHostPath.workloadScoped() {
+			return violations
+		}
+		scoped = true
+	}
+	message := fmt.Sprintf("hostPath '%s' of PersistentVolume '%s' is not in the AllowedHostPaths list", path, name)
+	if scoped {
+		message = fmt.Sprintf("hostPath '%s' of PersistentVolume '%s' is only allowed for some workloads by the AllowedHostPaths list",
+			path, name)
+	}
+	return append(violations, Violation{
+		Rule:    RuleNotAllowed,
+		Volume:  volume,
+		Message: message,
+	})
+}
+
+// persistentVolumeHostPathType returns the type of the `hostPath` of the
+// given PersistentVolume, empty when not set, and whether the volume is a
+// `hostPath` one.
+func persistentVolumeHostPathType(persistentVolume corev1.PersistentVolume) (string, bool) {
+	if persistentVolume.Spec == nil || persistentVolume.Spec.HostPath == nil {
+		return "", false
+	}
+	return persistentVolume.Spec.HostPath.Type, true
+}
+
+// workloadScoped returns whether the entry only applies to some workloads,
+// or requires something from the pods mounting the path.
+func (h HostPath) workloadScoped() bool {
+	return h.restricted() || len(h.RequireNodeLabels) > 0 ||
+		h.hasIdentityRequirements() || h.hasConfinementRequirements()
+}
+
+// restricted returns whether the entry only applies to some workloads.
+func (h HostPath) restricted() bool {
+	return len(h.AllowedKinds) > 0 || h.Selector != nil || len(h.AllowedRuntimeClasses) > 0 ||
+		len(h.AllowedImages) > 0
+}
+
+// samePathPrefix returns whether both entries have the same pathPrefix.
+func (h HostPath) samePathPrefix(other HostPath) bool {
+	return HasPathPrefix(h.PathPrefix, other.PathPrefix) && HasPathPrefix(other.PathPrefix, h.PathPrefix)
+}
+
+// overrides returns whether the entry takes precedence over the other entry
+// with the same pathPrefix, whatever their order: the entries restricted to
+// some workloads override the unrestricted ones.
+func (h HostPath) overrides(other HostPath) bool {
+	return h.samePathPrefix(other) && h.restricted() && !other.restricted()
+}
+
+// overlaps returns whether both entries have the same pathPrefix and can
+// apply to the same mounts, with none overriding the other. The restricted
+// entries are distinct when they allow distinct kinds, RuntimeClasses or
+// images, or select distinct labels. Identical entries don't overlap, they
+// are evaluated the same way whatever their order.
+func (h HostPath) overlaps(other HostPath) bool {
+	if !h.samePathPrefix(other) || h.restricted() != other.restricted() {
+		return false
+	}
+	other.PathPrefix = h.PathPrefix
+	if h.equal(other) {
+		return false
+	}
+	return !disjointValues(h.AllowedKinds, other.AllowedKinds) &&
+		!disjointValues(h.AllowedRuntimeClasses, other.AllowedRuntimeClasses) &&
+		!disjointImagePatterns(h.AllowedImages, other.AllowedImages) &&
+		!h.Selector.disjoint(other.Selector)
+}
+
+// equal returns whether both entries have the same JSON encoding, unset and
+// empty values being the same.
+func (h HostPath) equal(other HostPath) bool {
+	a, errA := json.Marshal(h)
+	b, errB := json.Marshal(other)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// disjointValues returns whether both lists restrict the values, to distinct
+// ones.
+func disjointValues(a, b []string) bool {
+	return len(a) > 0 && len(b) > 0 && !slices.ContainsFunc(a, func(value string) bool {
+		return slices.Contains(b, value)
+	})
+}
+
+// HasPathPrefix returns whether path is prefix or is inside of prefix.
+func HasPathPrefix(path string, prefix string) bool {
+	// allow "/foo", "/foo/", "/foo/bar", etc
+	// disallow "/fool", "/etc/foo", etc
+	// "/foo/../" is never valid.
+	// Hence, ensure paths terminate in `/`:
+	pathTerminated := path
+	if !strings.HasSuffix(pathTerminated, "/") {
+		pathTerminated = pathTerminated + "/"
+	}
+	prefixTerminated := prefix
+	if !strings.HasSuffix(prefixTerminated, "/") {
+		prefixTerminated = prefixTerminated + "/"
+	}
+	return strings.HasPrefix(pathTerminated, prefixTerminated)
+}
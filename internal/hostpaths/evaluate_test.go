@@ -0,0 +1,230 @@
+package hostpaths
+
+import (
+	"reflect"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestExtractPodSpecs(t *testing.T) {
+	cronJob := []byte(`{
+		"apiVersion": "batch/v1",
+		"kind": "CronJob",
+		"spec": {"jobTemplate": {"spec": {"template": {"spec": {"containers": []}}}}}
+	}`)
+	gvk := kubewarden_protocol.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"}
+
+	podSpecs, err := ExtractPodSpecs(gvk, cronJob, Settings{})
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if len(podSpecs) != 1 ||
+		podSpecs[0].Path != "spec.jobTemplate.spec.template.spec" ||
+		!podSpecs[0].Implicit {
+		t.Errorf("Unexpected pod specs %+v", podSpecs)
+	}
+
+	gvk.Kind = "Unknown"
+	if _, err = ExtractPodSpecs(gvk, cronJob, Settings{}); err == nil {
+		t.Errorf("Expected error for unknown kind")
+	}
+	if HasPodSpecs(gvk, Settings{}) {
+		t.Errorf("Unknown kind shouldn't have pod specs")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	object := []byte(`{
+		"spec": {
+			"initContainers": [
+				{"name": "init", "volumeMounts": [{"name": "logs", "mountPath": "/logs"}]}
+			],
+			"containers": [
+				{"name": "app", "volumeMounts": [{"name": "logs", "mountPath": "/logs", "readOnly": true}]}
+			],
+			"volumes": [
+				{"name": "logs", "hostPath": {"path": "/var/log"}}
+			]
+		}
+	}`)
+	settings := Settings{
+		AllowedHostPaths: []HostPath{{PathPrefix: "/var/log", ReadOnly: true}},
+	}
+	gvk := kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"}
+
+	podSpecs, err := ExtractPodSpecs(gvk, object, settings)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	violations := Evaluate(podSpecs[0], settings)
+	expected := []Violation{
+		{
+			Rule:      RuleReadOnly,
+			PodSpec:   "spec",
+			implicit:  true,
+			Volume:    Volume{Name: "logs", Path: "/var/log"},
+			Container: "init",
+			Mount:     "logs",
+			Message:   "hostPath '/var/log' mounted as 'logs' should be readOnly 'true'",
+		},
+	}
+	if !reflect.DeepEqual(violations, expected) {
+		t.Errorf("Wanted %+v, but got %+v instead", expected, violations)
+	}
+	if violations[0].Error() != expected[0].Message {
+		t.Errorf("Implicit pod spec path shouldn't be reported, got '%s'", violations[0].Error())
+	}
+}
+
+func TestValidateMountsProtectingReadOnlySubPaths(t *testing.T) {
+	allowedHostPaths := []HostPath{
+		{PathPrefix: "/var", ReadOnly: false},
+		{PathPrefix: "/var/local", ReadOnly: true},
+		{PathPrefix: "/var/local/cache", ReadOnly: false},
+	}
+
+	for _, tcase := range []struct {
+		name     string
+		mount    Mount
+		protect  bool
+		messages []string
+	}{
+		{
+			name:     "writable ancestor without protection",
+			mount:    Mount{Volume: Volume{Name: "var", Path: "/var"}, Name: "var"},
+			protect:  false,
+			messages: []string{},
+		},
+		{
+			name:    "writable ancestor",
+			mount:   Mount{Volume: Volume{Name: "var", Path: "/var"}, Name: "var"},
+			protect: true,
+			messages: []string{
+				"hostPath '/var' mounted as 'var' should be readOnly 'true', it contains the read-only allowed path '/var/local'",
+			},
+		},
+		{
+			name:     "read-only ancestor",
+			mount:    Mount{Volume: Volume{Name: "var", Path: "/var"}, Name: "var", ReadOnly: true},
+			protect:  true,
+			messages: []string{},
+		},
+		{
+			name:     "writable sibling",
+			mount:    Mount{Volume: Volume{Name: "lib", Path: "/var/lib"}, Name: "lib"},
+			protect:  true,
+			messages: []string{},
+		},
+		{
+			name:     "writable descendant of the read-only path",
+			mount:    Mount{Volume: Volume{Name: "cache", Path: "/var/local/cache/app"}, Name: "cache"},
+			protect:  true,
+			messages: []string{},
+		},
+		{
+			name:    "writable read-only path",
+			mount:   Mount{Volume: Volume{Name: "local", Path: "/var/local"}, Name: "local"},
+			protect: true,
+			messages: []string{
+				"hostPath '/var/local' mounted as 'local' should be readOnly 'true'",
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			settings := Settings{
+				AllowedHostPaths:        allowedHostPaths,
+				ProtectReadOnlySubPaths: tcase.protect,
+			}
+			violations := ValidateMounts(EmbeddedPodSpec{}, []Mount{tcase.mount}, settings)
+			messages := make([]string, 0, len(violations))
+			for _, violation := range violations {
+				messages = append(messages, violation.Message)
+			}
+			if !reflect.DeepEqual(messages, tcase.messages) {
+				t.Errorf("Wanted %q, but got %q instead", tcase.messages, messages)
+			}
+		})
+	}
+}
+
+func TestValidateMountsProtectingScopedReadOnlySubPaths(t *testing.T) {
+	writable := false
+	for _, tcase := range []struct {
+		name     string
+		subPath  HostPath
+		podSpec  EmbeddedPodSpec
+		role     string
+		messages []string
+	}{
+		{
+			name:     "writable for the init containers",
+			subPath:  HostPath{PathPrefix: "/cache/shared", ReadOnly: true, InitContainersReadOnly: &writable},
+			podSpec:  EmbeddedPodSpec{Kind: "Pod"},
+			role:     RoleInit,
+			messages: []string{},
+		},
+		{
+			name:    "read-only for the regular containers",
+			subPath: HostPath{PathPrefix: "/cache/shared", ReadOnly: true, InitContainersReadOnly: &writable},
+			podSpec: EmbeddedPodSpec{Kind: "Pod"},
+			role:    RoleContainer,
+			messages: []string{
+				"hostPath '/cache' mounted as 'cache' should be readOnly 'true', it contains the read-only allowed path '/cache/shared'",
+			},
+		},
+		{
+			name: "read-only for the sidecars",
+			subPath: HostPath{
+				PathPrefix:                "/cache/shared",
+				ReadOnly:                  false,
+				InitContainersReadOnly:    &writable,
+				SidecarContainersReadOnly: ptrBool(true),
+			},
+			podSpec: EmbeddedPodSpec{Kind: "Pod"},
+			role:    RoleSidecar,
+			messages: []string{
+				"hostPath '/cache' mounted as 'cache' should be readOnly 'true', it contains the read-only allowed path '/cache/shared'",
+			},
+		},
+		{
+			name: "selector not matching the pod",
+			subPath: HostPath{
+				PathPrefix: "/cache/shared",
+				ReadOnly:   true,
+				Selector:   &LabelSelector{MatchLabels: map[string]string{"app": "reader"}},
+			},
+			podSpec:  EmbeddedPodSpec{Kind: "Pod", Labels: map[string]string{"app": "writer"}},
+			role:     RoleContainer,
+			messages: []string{},
+		},
+		{
+			name:     "other kinds",
+			subPath:  HostPath{PathPrefix: "/cache/shared", ReadOnly: true, AllowedKinds: []string{"DaemonSet"}},
+			podSpec:  EmbeddedPodSpec{Kind: "Deployment"},
+			role:     RoleContainer,
+			messages: []string{},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			settings := Settings{
+				AllowedHostPaths:        []HostPath{{PathPrefix: "/cache", ReadOnly: false}, tcase.subPath},
+				ProtectReadOnlySubPaths: true,
+			}
+			mount := Mount{Volume: Volume{Name: "cache", Path: "/cache"}, Container: "app", Role: tcase.role, Name: "cache"}
+			violations := ValidateMounts(tcase.podSpec, []Mount{mount}, settings)
+			messages := make([]string, 0, len(violations))
+			for _, violation := range violations {
+				messages = append(messages, violation.Message)
+			}
+			if !reflect.DeepEqual(messages, tcase.messages) {
+				t.Errorf("Wanted %q, but got %q instead", tcase.messages, messages)
+			}
+		})
+	}
+}
+
+func ptrBool(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,126 @@
+package hostpaths
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// RuleIdentity is violated by the writable mounts of containers not running
+// with the identity required by their AllowedHostPaths entry.
+const RuleIdentity = "hostpath-writable-identity"
+
+// identityContext holds the identity and confinement fields of a pod or
+// container security context. They are pointers, unlike the corev1 ones: an
+// unset field inherits the value of the pod, an explicit zero value
+// overrides it.
+type identityContext struct {
+	RunAsNonRoot    *bool            `json:"runAsNonRoot"`
+	RunAsUser       *int64           `json:"runAsUser"`
+	SELinuxOptions  *seLinuxOptions  `json:"seLinuxOptions"`
+	AppArmorProfile *appArmorProfile `json:"appArmorProfile"`
+}
+
+type identityContainer struct {
+	Name            string           `json:"name"`
+	SecurityContext *identityContext `json:"securityContext"`
+}
+
+// identitySpec holds the identity fields of a pod spec.
+type identitySpec struct {
+	HostUsers           *bool               `json:"hostUsers"`
+	SecurityContext     *identityContext    `json:"securityContext"`
+	InitContainers      []identityContainer `json:"initContainers"`
+	Containers          []identityContainer `json:"containers"`
+	EphemeralContainers []identityContainer `json:"ephemeralContainers"`
+}
+
+// newIdentitySpec reads the identity fields of the given pod spec document.
+// Documents that cannot be read have no identity, and fail all the
+// requirements.
+func newIdentitySpec(raw []byte) *identitySpec {
+	spec := &identitySpec{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, spec); err != nil {
+			return &identitySpec{}
+		}
+	}
+	return spec
+}
+
+// effectiveContext returns the identity the given container runs with, the
+// fields of its security context override the ones of the pod.
+func (s *identitySpec) effectiveContext(containerName string) identityContext {
+	effective := identityContext{}
+	if s.SecurityContext != nil {
+		effective = *s.SecurityContext
+	}
+	for _, containers := range [][]identityContainer{s.InitContainers, s.Containers, s.EphemeralContainers} {
+		for _, container := range containers {
+			if container.Name != containerName || container.SecurityContext == nil {
+				continue
+			}
+			if container.SecurityContext.RunAsNonRoot != nil {
+				effective.RunAsNonRoot = container.SecurityContext.RunAsNonRoot
+			}
+			if container.SecurityContext.RunAsUser != nil {
+				effective.RunAsUser = container.SecurityContext.RunAsUser
+			}
+			if container.SecurityContext.SELinuxOptions != nil {
+				effective.SELinuxOptions = container.SecurityContext.SELinuxOptions
+			}
+			if container.SecurityContext.AppArmorProfile != nil {
+				effective.AppArmorProfile = container.SecurityContext.AppArmorProfile
+			}
+			return effective
+		}
+	}
+	return effective
+}
+
+func (h HostPath) hasIdentityRequirements() bool {
+	return h.RequireRunAsNonRoot || h.RequireHostUsersFalse || h.MinRunAsUser != nil || h.MaxRunAsUser != nil
+}
+
+// validate returns the messages of the identity requirements of the given
+// AllowedHostPaths entry not met by the writable mount.
+func (s *identitySpec) validate(mount Mount, hostPath HostPath) []string {
+	messages := make([]string, 0)
+	prefix := fmt.Sprintf("%s mounted as '%s' is writable", mount.Volume, mount.Name)
+	effective := s.effectiveContext(mount.Container)
+
+	if hostPath.RequireHostUsersFalse && (s.HostUsers == nil || *s.HostUsers) {
+		messages = append(messages, fmt.Sprintf("%s, the pod should set hostUsers 'false'", prefix))
+	}
+	if hostPath.RequireRunAsNonRoot && (effective.RunAsNonRoot == nil || !*effective.RunAsNonRoot) {
+		messages = append(messages, fmt.Sprintf("%s, container '%s' should set runAsNonRoot 'true'",
+			prefix, mount.Container))
+	}
+	if hostPath.MinRunAsUser != nil || hostPath.MaxRunAsUser != nil {
+		runAsUser := effective.RunAsUser
+		if runAsUser == nil ||
+			(hostPath.MinRunAsUser != nil && *runAsUser < *hostPath.MinRunAsUser) ||
+			(hostPath.MaxRunAsUser != nil && *runAsUser > *hostPath.MaxRunAsUser) {
+			message := fmt.Sprintf("%s, container '%s' should set runAsUser %s",
+				prefix, mount.Container, hostPath.runAsUserRange())
+			if runAsUser == nil {
+				message += ", it is not set"
+			} else {
+				message += fmt.Sprintf(", not %d", *runAsUser)
+			}
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// runAsUserRange describes the runAsUser range of the entry.
+func (h HostPath) runAsUserRange() string {
+	switch {
+	case h.MinRunAsUser != nil && h.MaxRunAsUser != nil:
+		return fmt.Sprintf("between %d and %d", *h.MinRunAsUser, *h.MaxRunAsUser)
+	case h.MinRunAsUser != nil:
+		return fmt.Sprintf("to at least %d", *h.MinRunAsUser)
+	default:
+		return fmt.Sprintf("to at most %d", *h.MaxRunAsUser)
+	}
+}
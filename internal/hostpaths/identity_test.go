@@ -0,0 +1,111 @@
+package hostpaths
+
+import (
+	"reflect"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func ptrInt64(i int64) *int64 {
+	return &i
+}
+
+func TestValidateMountsIdentityRequirements(t *testing.T) {
+	for _, tcase := range []struct {
+		name     string
+		hostPath HostPath
+		spec     string
+		messages []string
+	}{
+		{
+			name:     "no requirement",
+			hostPath: HostPath{PathPrefix: "/data"},
+			spec:     `{}`,
+			messages: []string{},
+		},
+		{
+			name:     "runAsNonRoot set by the pod",
+			hostPath: HostPath{PathPrefix: "/data", RequireRunAsNonRoot: true},
+			spec:     `{"securityContext": {"runAsNonRoot": true}}`,
+			messages: []string{},
+		},
+		{
+			name:     "runAsNonRoot overridden by the container",
+			hostPath: HostPath{PathPrefix: "/data", RequireRunAsNonRoot: true},
+			spec: `{
+				"securityContext": {"runAsNonRoot": true},
+				"containers": [{"name": "app", "securityContext": {"runAsNonRoot": false}}]
+			}`,
+			messages: []string{
+				"hostPath '/data/app' mounted as 'data' is writable, container 'app' should set runAsNonRoot 'true'",
+			},
+		},
+		{
+			name:     "runAsNonRoot not set",
+			hostPath: HostPath{PathPrefix: "/data", RequireRunAsNonRoot: true},
+			spec:     `{"containers": [{"name": "app"}]}`,
+			messages: []string{
+				"hostPath '/data/app' mounted as 'data' is writable, container 'app' should set runAsNonRoot 'true'",
+			},
+		},
+		{
+			name:     "hostUsers false",
+			hostPath: HostPath{PathPrefix: "/data", RequireHostUsersFalse: true},
+			spec:     `{"hostUsers": false}`,
+			messages: []string{},
+		},
+		{
+			name:     "hostUsers not set",
+			hostPath: HostPath{PathPrefix: "/data", RequireHostUsersFalse: true},
+			spec:     `{}`,
+			messages: []string{
+				"hostPath '/data/app' mounted as 'data' is writable, the pod should set hostUsers 'false'",
+			},
+		},
+		{
+			name:     "runAsUser inside of the range",
+			hostPath: HostPath{PathPrefix: "/data", MinRunAsUser: ptrInt64(1000), MaxRunAsUser: ptrInt64(2000)},
+			spec:     `{"securityContext": {"runAsUser": 0}, "containers": [{"name": "app", "securityContext": {"runAsUser": 1500}}]}`,
+			messages: []string{},
+		},
+		{
+			name:     "root user",
+			hostPath: HostPath{PathPrefix: "/data", MinRunAsUser: ptrInt64(1)},
+			spec:     `{"securityContext": {"runAsUser": 0}}`,
+			messages: []string{
+				"hostPath '/data/app' mounted as 'data' is writable, container 'app' should set runAsUser to at least 1, not 0",
+			},
+		},
+		{
+			name:     "runAsUser not set",
+			hostPath: HostPath{PathPrefix: "/data", MaxRunAsUser: ptrInt64(2000)},
+			spec:     `{}`,
+			messages: []string{
+				"hostPath '/data/app' mounted as 'data' is writable, container 'app' should set runAsUser to at most 2000, it is not set",
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			object := []byte(`{"spec": ` + tcase.spec + `}`)
+			gvk := kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"}
+			settings := Settings{AllowedHostPaths: []HostPath{tcase.hostPath}}
+			podSpecs, err := ExtractPodSpecs(gvk, object, settings)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			mounts := []Mount{
+				{Volume: Volume{Name: "data", Path: "/data/app"}, Container: "app", Name: "data"},
+			}
+			violations := ValidateMounts(podSpecs[0], mounts, settings)
+			messages := make([]string, 0, len(violations))
+			for _, violation := range violations {
+				messages = append(messages, violation.Message)
+			}
+			if !reflect.DeepEqual(messages, tcase.messages) {
+				t.Errorf("Wanted %q, but got %q instead", tcase.messages, messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package hostpaths
+
+import (
+	"path"
+	"strings"
+)
+
+// RuleImageNotAllowed is violated by the mounts of the containers whose
+// images are not allowed by the AllowedHostPaths entries of their paths.
+const RuleImageNotAllowed = "hostpath-image-not-allowed"
+
+// imageReference is an image reference split into its parts. The repository
+// is normalized like the container runtimes do, `nginx` being
+// `docker.io/library/nginx`.
+type imageReference struct {
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseImage splits the image reference, or the image pattern, written as
+// `repository[:tag][@digest]`.
+func parseImage(image string) imageReference {
+	reference := imageReference{}
+	image, reference.Digest, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, reference.Tag = image[:i], image[i+1:]
+	}
+	reference.Repository = normalizeRepository(image)
+	return reference
+}
+
+// normalizeRepository prefixes the repositories of Docker Hub with its
+// domain, and its official images with `library/`. The first component of the
+// repository is a domain when it holds a dot, a port or a glob, or when it is
+// `localhost`.
+func normalizeRepository(repository string) string {
+	domain, _, found := strings.Cut(repository, "/")
+	switch {
+	case !found && !strings.ContainsAny(repository, "*?["):
+		return "docker.io/library/" + repository
+	case found && domain != "localhost" && !strings.ContainsAny(domain, ".:*?["):
+		return "docker.io/" + repository
+	default:
+		return repository
+	}
+}
+
+// matchesImage returns whether the image matches the pattern. The repository,
+// the tag and the digest of the pattern are globs, `*` not matching the `/`
+// separators of the repository. The tag and the digest are optional, a
+// pattern with a digest like `sha256:*` only matches images pinned by digest.
+func matchesImage(pattern, image string) bool {
+	p, i := parseImage(pattern), parseImage(image)
+	if matched, _ := path.Match(p.Repository, i.Repository); !matched {
+		return false
+	}
+	if p.Tag != "" {
+		if matched, _ := path.Match(p.Tag, i.Tag); !matched {
+			return false
+		}
+	}
+	if p.Digest != "" {
+		if matched, _ := path.Match(p.Digest, i.Digest); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// validateImagePattern returns the problem of the pattern, if any.
+func validateImagePattern(pattern string) string {
+	p := parseImage(pattern)
+	for _, glob := range []string{p.Repository, p.Tag, p.Digest} {
+		if _, err := path.Match(glob, ""); err != nil {
+			return "invalid image pattern '" + pattern + "'"
+		}
+	}
+	return ""
+}
+
+// disjointImagePatterns returns whether both lists restrict the images, and
+// no image can match a pattern of each list.
+func disjointImagePatterns(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	for _, patternA := range a {
+		for _, patternB := range b {
+			if imagePatternsOverlap(patternA, patternB) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// imagePatternsOverlap returns whether an image can match both patterns. The
+// answer is conservative: patterns whose globs can't be compared exactly are
+// considered overlapping.
+func imagePatternsOverlap(a, b string) bool {
+	pa, pb := parseImage(a), parseImage(b)
+	if !globsOverlap(pa.Repository, pb.Repository) {
+		return false
+	}
+	if pa.Tag != "" && pb.Tag != "" && !globsOverlap(pa.Tag, pb.Tag) {
+		return false
+	}
+	if pa.Digest != "" && pb.Digest != "" && !globsOverlap(pa.Digest, pb.Digest) {
+		return false
+	}
+	return true
+}
+
+// globsOverlap returns whether a string can match both globs. Literal values
+// are matched against the other glob, two globs overlap unless their literal
+// prefixes differ.
+func globsOverlap(a, b string) bool {
+	i, j := strings.IndexAny(a, "*?["), strings.IndexAny(b, "*?[")
+	switch {
+	case i < 0 && j < 0:
+		return a == b
+	case i < 0:
+		matched, _ := path.Match(b, a)
+		return matched
+	case j < 0:
+		matched, _ := path.Match(a, b)
+		return matched
+	default:
+		return strings.HasPrefix(a[:i], b[:j]) || strings.HasPrefix(b[:j], a[:i])
+	}
+}
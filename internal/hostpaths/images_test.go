@@ -0,0 +1,121 @@
+package hostpaths
+
+import (
+	"reflect"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestMatchesImage(t *testing.T) {
+	const digest = "sha256:4d5f8a1c0d0e3c6f1b7a9e2d3c4b5a69788796a5b4c3d2e1f0a9b8c7d6e5f4a3"
+	for _, tcase := range []struct {
+		pattern string
+		image   string
+		matches bool
+	}{
+		{"registry.internal/observability/fluent-bit", "registry.internal/observability/fluent-bit:3.0", true},
+		{"registry.internal/observability/fluent-bit", "registry.internal/observability/fluent-bit-debug:3.0", false},
+		{"registry.internal/observability/*", "registry.internal/observability/fluent-bit", true},
+		{"registry.internal/observability/*", "registry.internal/observability/team/fluent-bit", false},
+		{"registry.internal/*/fluent-bit", "registry.internal/observability/fluent-bit", true},
+		{"registry.internal/observability/fluent-bit:3.*", "registry.internal/observability/fluent-bit:3.1", true},
+		{"registry.internal/observability/fluent-bit:3.*", "registry.internal/observability/fluent-bit:2.2", false},
+		{"registry.internal/observability/fluent-bit:3.*", "registry.internal/observability/fluent-bit", false},
+		{"registry.internal/observability/fluent-bit@sha256:*", "registry.internal/observability/fluent-bit:3.0", false},
+		{"registry.internal/observability/fluent-bit@sha256:*", "registry.internal/observability/fluent-bit:3.0@" + digest, true},
+		{"registry.internal/observability/fluent-bit@" + digest, "registry.internal/observability/fluent-bit@" + digest, true},
+		{"registry.internal:5000/fluent-bit", "registry.internal:5000/fluent-bit:3.0", true},
+		{"nginx", "docker.io/library/nginx:1.27", true},
+		{"docker.io/library/nginx", "nginx", true},
+		{"bitnami/*", "docker.io/bitnami/fluent-bit", true},
+		{"localhost/fluent-bit", "localhost/fluent-bit", true},
+	} {
+		if matches := matchesImage(tcase.pattern, tcase.image); matches != tcase.matches {
+			t.Errorf("Pattern '%s' on image '%s': wanted %t, but got %t instead",
+				tcase.pattern, tcase.image, tcase.matches, matches)
+		}
+	}
+}
+
+func TestImagePatternsOverlap(t *testing.T) {
+	for _, tcase := range []struct {
+		a, b     string
+		overlaps bool
+	}{
+		{"nginx", "docker.io/library/nginx", true},
+		{"nginx", "redis", false},
+		{"nginx", "nginx-unprivileged", false},
+		{"nginx:1.25", "nginx:1.27", false},
+		{"nginx:1.*", "nginx:1.27", true},
+		{"nginx:1.*", "nginx", true},
+		{"registry.internal/observability/*", "registry.internal/observability/fluent-bit", true},
+		{"registry.internal/observability/*", "registry.internal/storage/*", false},
+		{"registry.internal/*/fluent-bit", "registry.internal/observability/*", true},
+		{"registry.internal/*", "*/fluent-bit", true},
+	} {
+		if overlaps := imagePatternsOverlap(tcase.a, tcase.b); overlaps != tcase.overlaps {
+			t.Errorf("Patterns '%s' and '%s': wanted %t, but got %t instead", tcase.a, tcase.b, tcase.overlaps, overlaps)
+		}
+		if overlaps := imagePatternsOverlap(tcase.b, tcase.a); overlaps != tcase.overlaps {
+			t.Errorf("Patterns '%s' and '%s': wanted %t, but got %t instead", tcase.b, tcase.a, tcase.overlaps, overlaps)
+		}
+	}
+}
+
+func TestValidateMountsAllowedImages(t *testing.T) {
+	settings := Settings{AllowedHostPaths: []HostPath{
+		{PathPrefix: "/var/log", ReadOnly: true},
+		{
+			PathPrefix:    "/var/log/containers",
+			ReadOnly:      false,
+			AllowedImages: []string{"registry.internal/observability/fluent-bit@sha256:*"},
+		},
+	}}
+	gvk := kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	object := []byte(`{"spec": {
+		"volumes": [{"name": "logs", "hostPath": {"path": "/var/log/containers"}}],
+		"initContainers": [{"name": "init", "image": "busybox", "volumeMounts": [{"name": "logs", "mountPath": "/logs"}]}],
+		"containers": [
+			{"name": "fluent-bit", "image": "registry.internal/observability/fluent-bit@sha256:0123", "volumeMounts": [{"name": "logs", "mountPath": "/logs"}]},
+			{"name": "reader", "image": "busybox", "volumeMounts": [{"name": "logs", "mountPath": "/logs", "readOnly": true}]}
+		],
+		"ephemeralContainers": [{"name": "debug", "image": "registry.internal/observability/fluent-bit:3.0", "volumeMounts": [{"name": "logs", "mountPath": "/logs"}]}]
+	}}`)
+
+	podSpecs, err := ExtractPodSpecs(gvk, object, settings)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	violations := Evaluate(podSpecs[0], settings)
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		messages = append(messages, violation.Message)
+	}
+	expected := []string{
+		"hostPath '/var/log/containers' mounted as 'logs' should be readOnly 'true'",
+		"hostPath '/var/log/containers' mounted as 'logs' should be readOnly 'true'",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("Wanted %q, but got %q instead", expected, messages)
+	}
+
+	// without a less specific entry, the image is reported
+	settings.AllowedHostPaths = settings.AllowedHostPaths[1:]
+	violations = Evaluate(podSpecs[0], settings)
+	messages = make([]string, 0, len(violations))
+	for _, violation := range violations {
+		if violation.Rule != RuleImageNotAllowed {
+			t.Errorf("Unexpected rule %s", violation.Rule)
+		}
+		messages = append(messages, violation.Message)
+	}
+	expected = []string{
+		"hostPath '/var/log/containers' mounted as 'logs' is not allowed for image 'busybox' of container 'init', only for 'registry.internal/observability/fluent-bit@sha256:*'",
+		"hostPath '/var/log/containers' mounted as 'logs' is not allowed for image 'busybox' of container 'reader', only for 'registry.internal/observability/fluent-bit@sha256:*'",
+		"hostPath '/var/log/containers' mounted as 'logs' is not allowed for image 'registry.internal/observability/fluent-bit:3.0' of container 'debug', only for 'registry.internal/observability/fluent-bit@sha256:*'",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("Wanted %q, but got %q instead", expected, messages)
+	}
+}
@@ -0,0 +1,94 @@
+package hostpaths
+
+import (
+	"reflect"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestValidateMountsAllowedKinds(t *testing.T) {
+	settings := Settings{AllowedHostPaths: []HostPath{
+		{PathPrefix: "/var", ReadOnly: true},
+		{PathPrefix: "/var/log", ReadOnly: false, AllowedKinds: []string{"DaemonSet"}},
+		{PathPrefix: "/data", ReadOnly: false, AllowedKinds: []string{"DaemonSet", "StatefulSet"}},
+	}}
+
+	for _, tcase := range []struct {
+		name     string
+		gvk      kubewarden_protocol.GroupVersionKind
+		object   string
+		path     string
+		readOnly bool
+		messages []string
+	}{
+		{
+			name:     "allowed kind",
+			gvk:      kubewarden_protocol.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+			object:   `{"spec": {"template": {"spec": {}}}}`,
+			path:     "/var/log/app",
+			messages: []string{},
+		},
+		{
+			name:     "pod owned by an allowed kind",
+			gvk:      kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			object:   `{"metadata": {"ownerReferences": [{"kind": "DaemonSet", "name": "agent", "controller": true}]}, "spec": {}}`,
+			path:     "/var/log/app",
+			messages: []string{},
+		},
+		{
+			name:   "pod owned by another kind",
+			gvk:    kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			object: `{"metadata": {"ownerReferences": [{"kind": "ReplicaSet", "name": "web", "controller": true}]}, "spec": {}}`,
+			path:   "/data/app",
+			messages: []string{
+				"hostPath '/data/app' mounted as 'data' is not allowed for kind 'Pod' owned by a 'ReplicaSet', only for 'DaemonSet' or 'StatefulSet'",
+			},
+		},
+		{
+			name:   "bare pod",
+			gvk:    kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			object: `{"spec": {}}`,
+			path:   "/data/app",
+			messages: []string{
+				"hostPath '/data/app' mounted as 'data' is not allowed for kind 'Pod', only for 'DaemonSet' or 'StatefulSet'",
+			},
+		},
+		{
+			name:   "less specific entry of all the kinds",
+			gvk:    kubewarden_protocol.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			object: `{"spec": {"template": {"spec": {}}}}`,
+			path:   "/var/log/app",
+			messages: []string{
+				"hostPath '/var/log/app' mounted as 'data' should be readOnly 'true'",
+			},
+		},
+		{
+			name:     "less specific entry mounted read-only",
+			gvk:      kubewarden_protocol.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			object:   `{"spec": {"template": {"spec": {}}}}`,
+			path:     "/var/log/app",
+			readOnly: true,
+			messages: []string{},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			podSpecs, err := ExtractPodSpecs(tcase.gvk, []byte(tcase.object), settings)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			mounts := []Mount{
+				{Volume: Volume{Name: "data", Path: tcase.path}, Container: "app", Name: "data", ReadOnly: tcase.readOnly},
+			}
+			violations := ValidateMounts(podSpecs[0], mounts, settings)
+			messages := make([]string, 0, len(violations))
+			for _, violation := range violations {
+				messages = append(messages, violation.Message)
+			}
+			if !reflect.DeepEqual(messages, tcase.messages) {
+				t.Errorf("Wanted %q, but got %q instead", tcase.messages, messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package hostpaths
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+// RuleNodePinning is violated by the mounts of pods not pinned to the nodes
+// labelled as required by their AllowedHostPaths entry.
+const RuleNodePinning = "hostpath-node-pinning"
+
+// validateNodePinning returns the messages of the node labels required by the
+// given AllowedHostPaths entry that the pod isn't restricted to.
+func validateNodePinning(podSpec corev1.PodSpec, mount Mount, hostPath HostPath) []string {
+	missing := make([]string, 0)
+	for key, value := range hostPath.RequireNodeLabels {
+		if !pinnedToNodeLabel(podSpec, key, value) {
+			missing = append(missing, fmt.Sprintf("'%s=%s'", key, value))
+		}
+	}
+	if len(missing) == 0 {
+		return []string{}
+	}
+	sort.Strings(missing)
+	return []string{fmt.Sprintf(
+		"%s mounted as '%s' requires the pod to run on the nodes labelled %s, it should select them with its nodeSelector or a required node affinity",
+		mount.Volume, mount.Name, strings.Join(missing, " and "))}
+}
+
+// pinnedToNodeLabel returns whether the pod can only be scheduled on the nodes
+// with the given label: either its nodeSelector requires the label, or each
+// of the terms of its required node affinity, which are ORed, does with an
+// `In` expression.
+func pinnedToNodeLabel(podSpec corev1.PodSpec, key, value string) bool {
+	if actual, found := podSpec.NodeSelector[key]; found && actual == value {
+		return true
+	}
+	if podSpec.Affinity == nil || podSpec.Affinity.NodeAffinity == nil ||
+		podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return false
+	}
+	terms := podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) == 0 {
+		return false
+	}
+	for _, term := range terms {
+		if term == nil || !termRequiresNodeLabel(term, key, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// termRequiresNodeLabel returns whether the node selector term only matches
+// the nodes with the given label.
+func termRequiresNodeLabel(term *corev1.NodeSelectorTerm, key, value string) bool {
+	for _, requirement := range term.MatchExpressions {
+		if requirement == nil || requirement.Key == nil || *requirement.Key != key ||
+			requirement.Operator == nil || *requirement.Operator != "In" || len(requirement.Values) == 0 {
+			continue
+		}
+		restricted := true
+		for _, v := range requirement.Values {
+			if v != value {
+				restricted = false
+			}
+		}
+		if restricted {
+			return true
+		}
+	}
+	return false
+}
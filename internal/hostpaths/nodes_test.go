@@ -0,0 +1,101 @@
+package hostpaths
+
+import (
+	"reflect"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestValidateMountsNodePinning(t *testing.T) {
+	settings := Settings{AllowedHostPaths: []HostPath{
+		{
+			PathPrefix:        "/var/lib/agent",
+			ReadOnly:          false,
+			RequireNodeLabels: map[string]string{"pool": "agents", "tier": "system"},
+		},
+	}}
+
+	for _, tcase := range []struct {
+		name     string
+		spec     string
+		messages []string
+	}{
+		{
+			name:     "nodeSelector",
+			spec:     `{"nodeSelector": {"pool": "agents", "tier": "system", "zone": "a"}}`,
+			messages: []string{},
+		},
+		{
+			name: "required node affinity",
+			spec: `{"affinity": {"nodeAffinity": {"requiredDuringSchedulingIgnoredDuringExecution": {"nodeSelectorTerms": [
+				{"matchExpressions": [{"key": "pool", "operator": "In", "values": ["agents"]}, {"key": "tier", "operator": "In", "values": ["system"]}]}
+			]}}}}`,
+			messages: []string{},
+		},
+		{
+			name: "nodeSelector and required node affinity",
+			spec: `{"nodeSelector": {"tier": "system"}, "affinity": {"nodeAffinity": {"requiredDuringSchedulingIgnoredDuringExecution": {"nodeSelectorTerms": [
+				{"matchExpressions": [{"key": "pool", "operator": "In", "values": ["agents"]}]},
+				{"matchExpressions": [{"key": "pool", "operator": "In", "values": ["agents"]}], "matchFields": [{"key": "metadata.name", "operator": "In", "values": ["node-1"]}]}
+			]}}}}`,
+			messages: []string{},
+		},
+		{
+			name: "term allowing other nodes",
+			spec: `{"nodeSelector": {"tier": "system"}, "affinity": {"nodeAffinity": {"requiredDuringSchedulingIgnoredDuringExecution": {"nodeSelectorTerms": [
+				{"matchExpressions": [{"key": "pool", "operator": "In", "values": ["agents"]}]},
+				{"matchExpressions": [{"key": "zone", "operator": "In", "values": ["a"]}]}
+			]}}}}`,
+			messages: []string{
+				"hostPath '/var/lib/agent' mounted as 'data' requires the pod to run on the nodes labelled 'pool=agents', it should select them with its nodeSelector or a required node affinity",
+			},
+		},
+		{
+			name: "expression allowing other values",
+			spec: `{"nodeSelector": {"tier": "system"}, "affinity": {"nodeAffinity": {"requiredDuringSchedulingIgnoredDuringExecution": {"nodeSelectorTerms": [
+				{"matchExpressions": [{"key": "pool", "operator": "In", "values": ["agents", "general"]}]}
+			]}}}}`,
+			messages: []string{
+				"hostPath '/var/lib/agent' mounted as 'data' requires the pod to run on the nodes labelled 'pool=agents', it should select them with its nodeSelector or a required node affinity",
+			},
+		},
+		{
+			name: "preferred node affinity",
+			spec: `{"affinity": {"nodeAffinity": {"preferredDuringSchedulingIgnoredDuringExecution": [
+				{"weight": 1, "preference": {"matchExpressions": [{"key": "pool", "operator": "In", "values": ["agents"]}]}}
+			]}}}`,
+			messages: []string{
+				"hostPath '/var/lib/agent' mounted as 'data' requires the pod to run on the nodes labelled 'pool=agents' and 'tier=system', it should select them with its nodeSelector or a required node affinity",
+			},
+		},
+		{
+			name: "unpinned",
+			spec: `{"nodeSelector": {"pool": "general"}}`,
+			messages: []string{
+				"hostPath '/var/lib/agent' mounted as 'data' requires the pod to run on the nodes labelled 'pool=agents' and 'tier=system', it should select them with its nodeSelector or a required node affinity",
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			object := []byte(`{"spec": ` + tcase.spec + `}`)
+			gvk := kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"}
+			podSpecs, err := ExtractPodSpecs(gvk, object, settings)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			mounts := []Mount{
+				{Volume: Volume{Name: "data", Path: "/var/lib/agent"}, Container: "agent", Name: "data"},
+			}
+			violations := ValidateMounts(podSpecs[0], mounts, settings)
+			messages := make([]string, 0, len(violations))
+			for _, violation := range violations {
+				messages = append(messages, violation.Message)
+			}
+			if !reflect.DeepEqual(messages, tcase.messages) {
+				t.Errorf("Wanted %q, but got %q instead", tcase.messages, messages)
+			}
+		})
+	}
+}
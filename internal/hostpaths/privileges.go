@@ -0,0 +1,110 @@
+package hostpaths
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scopes of the privilege guard, see Settings.PrivilegeGuard.
+const (
+	// PrivilegeGuardNever disables the guard, the default
+	PrivilegeGuardNever = "never"
+	// PrivilegeGuardHostPaths inspects the pods using hostPath volumes
+	PrivilegeGuardHostPaths = "hostPaths"
+	// PrivilegeGuardAlways inspects all the pods
+	PrivilegeGuardAlways = "always"
+)
+
+// Rules of the privilege guard.
+const (
+	// RulePrivileged is violated by the privileged containers
+	RulePrivileged = "privileged-container"
+	// RuleCapability is violated by the containers adding one of the
+	// dangerous capabilities
+	RuleCapability = "dangerous-capability"
+)
+
+// defaultDangerousCapabilities are the capabilities rejected by the privilege
+// guard when the settings don't list any: CAP_SYS_ADMIN allows mounting any
+// path of the node.
+var defaultDangerousCapabilities = []string{"SYS_ADMIN"}
+
+// PrivilegeGuardEnabled returns whether the privilege guard inspects some
+// pods. It is enforced even when the AllowedHostPaths list is empty.
+func (s *Settings) PrivilegeGuardEnabled() bool {
+	return s.PrivilegeGuard == PrivilegeGuardHostPaths || s.PrivilegeGuard == PrivilegeGuardAlways
+}
+
+// DangerousCapabilitiesOrDefault returns the capabilities rejected by the
+// privilege guard, normalized like normalizeCapability.
+func (s *Settings) DangerousCapabilitiesOrDefault() []string {
+	capabilities := s.DangerousCapabilities
+	if len(capabilities) == 0 {
+		capabilities = defaultDangerousCapabilities
+	}
+	normalized := make([]string, 0, len(capabilities))
+	for _, capability := range capabilities {
+		normalized = append(normalized, normalizeCapability(capability))
+	}
+	return normalized
+}
+
+// normalizeCapability returns the name of the capability without the `CAP_`
+// prefix, upper-cased, like the container runtimes accept them.
+func normalizeCapability(capability string) string {
+	return strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+}
+
+// EvaluatePrivileges returns the containers of the given pod spec that can
+// mount any path of the node by themselves, whatever the AllowedHostPaths
+// list says: the privileged ones, and the ones adding a dangerous capability.
+// Depending on the PrivilegeGuard setting, the pod specs are inspected
+// always, or only when they use hostPath volumes.
+func EvaluatePrivileges(podSpec EmbeddedPodSpec, usesHostPaths bool, settings Settings) []Violation {
+	violations := make([]Violation, 0)
+	switch settings.PrivilegeGuard {
+	case PrivilegeGuardAlways:
+	case PrivilegeGuardHostPaths:
+		if !usesHostPaths {
+			return violations
+		}
+	default:
+		return violations
+	}
+
+	dangerousCapabilities := settings.DangerousCapabilitiesOrDefault()
+	for _, container := range Containers(podSpec.Spec) {
+		containerName := ContainerName(container)
+		newViolation := func(rule, message string) Violation {
+			return Violation{
+				Rule:      rule,
+				PodSpec:   podSpec.Path,
+				implicit:  podSpec.Implicit,
+				Container: containerName,
+				Message:   message,
+			}
+		}
+
+		securityContext := container.SecurityContext
+		if securityContext == nil {
+			continue
+		}
+		if securityContext.Privileged {
+			violations = append(violations, newViolation(RulePrivileged, fmt.Sprintf(
+				"container '%s' is privileged, it can mount any host path", containerName)))
+		}
+		if securityContext.Capabilities == nil {
+			continue
+		}
+		for _, capability := range securityContext.Capabilities.Add {
+			normalized := normalizeCapability(capability)
+			if normalized == "ALL" || slices.Contains(dangerousCapabilities, normalized) {
+				violations = append(violations, newViolation(RuleCapability, fmt.Sprintf(
+					"container '%s' adds the '%s' capability, it can mount any host path",
+					containerName, capability)))
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,172 @@
+package hostpaths
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// questionsGroup is the group of all the questions of the policy.
+const questionsGroup = "Settings"
+
+// questionsLineWidth is the width the folded descriptions are wrapped at.
+const questionsLineWidth = 76
+
+// WriteQuestions writes the Rancher questions-ui.yml file of the policy,
+// generated from the Settings type like SettingsSchema. Every field is a
+// question labeled by its `label` tag, described by its `description` and
+// `tooltip` tags. Strings with `enum` options are `enum` questions defaulting
+// to the first option, optional booleans and integers are questions without
+// default. Lists of strings are `array[` questions, maps of strings are `map[`
+// questions, lists of structs are `sequence[` questions whose items are
+// described by nested `sequence_questions`. The fields of nested structs are
+// questions of their own, whose variables are prefixed by the name of the
+// struct, like `selector.matchLabels`.
+func WriteQuestions(w io.Writer) error {
+	b := strings.Builder{}
+	b.WriteString("questions:\n")
+	if err := writeQuestions(&b, reflect.TypeOf(Settings{}), "", ""); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeQuestions(b *strings.Builder, t reflect.Type, indent, prefix string) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if nested := field.Type; nested.Kind() == reflect.Struct ||
+			(nested.Kind() == reflect.Pointer && nested.Elem().Kind() == reflect.Struct) {
+			if nested.Kind() == reflect.Pointer {
+				nested = nested.Elem()
+			}
+			if err := writeQuestions(b, nested, indent, prefix+name+"."); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var options []string
+		for _, option := range strings.Split(field.Tag.Get("jsonschema"), ",") {
+			if values, found := strings.CutPrefix(option, "enum="); found {
+				options = strings.Split(values, "|")
+			}
+		}
+
+		var questionType, defaultValue string
+		var items reflect.Type
+		switch {
+		case field.Type.Kind() == reflect.Bool:
+			questionType, defaultValue = "boolean", "false"
+		case field.Type.Kind() == reflect.String && options != nil:
+			questionType, defaultValue = "enum", options[0]
+		case field.Type.Kind() == reflect.String:
+			questionType, defaultValue = "string", "''"
+		case field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Bool:
+			// optional, without default
+			questionType = "boolean"
+		case field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Int64:
+			// optional, without default
+			questionType = "int"
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.String:
+			// Rancher syntax of the lists of strings
+			questionType, defaultValue = "array[", "[]"
+		case field.Type.Kind() == reflect.Map && field.Type.Elem().Kind() == reflect.String:
+			// Rancher syntax of the maps of strings
+			questionType, defaultValue = "map[", "{}"
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			// Rancher syntax of the lists of objects
+			questionType, defaultValue = "sequence[", "[]"
+			items = field.Type.Elem()
+		default:
+			return fmt.Errorf("no question type for field %s of type %s", field.Name, field.Type)
+		}
+
+		inner := indent + "  "
+		first := true
+		line := func(format string, args ...any) {
+			// the first key starts the item of the list
+			if first {
+				b.WriteString(indent + "- ")
+				first = false
+			} else {
+				b.WriteString(inner)
+			}
+			fmt.Fprintf(b, format+"\n", args...)
+		}
+
+		if defaultValue != "" {
+			line("default: %s", defaultValue)
+		}
+		if description := field.Tag.Get("description"); description != "" {
+			line("description: >-")
+			for _, text := range wrap(description, questionsLineWidth-len(inner)-2) {
+				fmt.Fprintf(b, "%s  %s\n", inner, text)
+			}
+		}
+		if tooltip := field.Tag.Get("tooltip"); tooltip != "" {
+			line("tooltip: %s", yamlString(tooltip))
+		}
+		line("group: %s", questionsGroup)
+		line("label: %s", yamlString(field.Tag.Get("label")))
+		if items != nil {
+			line("hide_input: true")
+		}
+		line("type: %s", questionType)
+		if questionType == "enum" {
+			line("options:")
+			for _, option := range options {
+				fmt.Fprintf(b, "%s  - %s\n", inner, yamlString(option))
+			}
+		}
+		if questionType == "array[" {
+			line("value_multiline: false")
+		}
+		line("variable: %s", prefix+name)
+		if items != nil {
+			line("sequence_questions:")
+			if err := writeQuestions(b, items, inner+"  ", ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// wrap splits the text into lines no longer than width, unless a single word
+// is longer.
+func wrap(text string, width int) []string {
+	lines := make([]string, 0)
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// yamlString returns the plain YAML scalar of the string when it is safe, its
+// quoted JSON string otherwise.
+func yamlString(s string) string {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		quoted, _ := json.Marshal(s)
+		return string(quoted)
+	}
+	return s
+}
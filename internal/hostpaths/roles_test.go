@@ -0,0 +1,87 @@
+package hostpaths
+
+import (
+	"reflect"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestValidateMountsContainerRoles(t *testing.T) {
+	readOnly, writable := true, false
+	// the init container warms up the cache read by the other containers
+	object := []byte(`{"spec": {
+		"volumes": [{"name": "cache", "hostPath": {"path": "/var/cache/app"}}],
+		"initContainers": [
+			{"name": "warmup", "volumeMounts": [{"name": "cache", "mountPath": "/cache"}]},
+			{"name": "sidecar", "restartPolicy": "Always", "volumeMounts": [{"name": "cache", "mountPath": "/cache"}]}
+		],
+		"containers": [{"name": "app", "volumeMounts": [{"name": "cache", "mountPath": "/cache", "readOnly": true}]}],
+		"ephemeralContainers": [{"name": "debug", "volumeMounts": [{"name": "cache", "mountPath": "/cache"}]}]
+	}}`)
+
+	for _, tcase := range []struct {
+		name     string
+		hostPath HostPath
+		messages []string
+	}{
+		{
+			name:     "same attribute for all the roles",
+			hostPath: HostPath{PathPrefix: "/var/cache", ReadOnly: true},
+			messages: []string{
+				"hostPath '/var/cache/app' mounted as 'cache' should be readOnly 'true'",
+				"hostPath '/var/cache/app' mounted as 'cache' should be readOnly 'true'",
+				"hostPath '/var/cache/app' mounted as 'cache' should be readOnly 'true'",
+			},
+		},
+		{
+			name:     "writable for the init containers only",
+			hostPath: HostPath{PathPrefix: "/var/cache", ReadOnly: true, InitContainersReadOnly: &writable},
+			messages: []string{
+				"hostPath '/var/cache/app' mounted as 'cache' should be readOnly 'true'",
+				"hostPath '/var/cache/app' mounted as 'cache' should be readOnly 'true'",
+			},
+		},
+		{
+			name: "attribute of every role",
+			hostPath: HostPath{
+				PathPrefix:                  "/var/cache",
+				ReadOnly:                    true,
+				InitContainersReadOnly:      &writable,
+				SidecarContainersReadOnly:   &writable,
+				EphemeralContainersReadOnly: &writable,
+			},
+			messages: []string{},
+		},
+		{
+			name: "read-only ephemeral containers",
+			hostPath: HostPath{
+				PathPrefix:                  "/var/cache",
+				ReadOnly:                    false,
+				EphemeralContainersReadOnly: &readOnly,
+			},
+			messages: []string{
+				"hostPath '/var/cache/app' mounted as 'cache' should be readOnly 'false'",
+				"hostPath '/var/cache/app' mounted as 'cache' by ephemeral container 'debug' should be readOnly 'true'",
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			settings := Settings{AllowedHostPaths: []HostPath{tcase.hostPath}}
+			gvk := kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"}
+			podSpecs, err := ExtractPodSpecs(gvk, object, settings)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			violations := Evaluate(podSpecs[0], settings)
+			messages := make([]string, 0, len(violations))
+			for _, violation := range violations {
+				messages = append(messages, violation.Message)
+			}
+			if !reflect.DeepEqual(messages, tcase.messages) {
+				t.Errorf("Wanted %q, but got %q instead", tcase.messages, messages)
+			}
+		})
+	}
+}
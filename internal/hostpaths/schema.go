@@ -0,0 +1,168 @@
+package hostpaths
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// schemaDialect is the JSON Schema draft the settings schema conforms to.
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema used to describe the settings.
+type Schema struct {
+	Dialect     string             `json:"$schema,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        string             `json:"type"`
+	Enum        []string           `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties describes the values of the maps
+	AdditionalProperties *Schema  `json:"additionalProperties,omitempty"`
+	Required             []string `json:"required,omitempty"`
+	Items                *Schema  `json:"items,omitempty"`
+	MinLength            *int     `json:"minLength,omitempty"`
+	MinItems             *int     `json:"minItems,omitempty"`
+	Minimum              *int     `json:"minimum,omitempty"`
+}
+
+var (
+	settingsSchema     *Schema
+	settingsSchemaOnce sync.Once
+)
+
+// SettingsSchema returns the JSON Schema of the settings, see
+// generateSettingsSchema. The TinyGo builds of the policy decode the
+// generated settings.schema.json file instead, embedded into the Wasm module,
+// to leave the reflection based generator out of it.
+func SettingsSchema() *Schema {
+	settingsSchemaOnce.Do(func() {
+		settingsSchema = loadSettingsSchema()
+	})
+	return settingsSchema
+}
+
+// SchemaError is a value not matching the schema, located by the JSON Pointer
+// of the value inside of the validated document.
+type SchemaError struct {
+	Pointer string
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	pointer := e.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	return fmt.Sprintf("%s: %s", pointer, e.Message)
+}
+
+// Validate validates a document decoded by encoding/json against the schema,
+// and returns all the errors found. Properties not described by the schema
+// are ignored, unless the schema describes additional properties.
+func (s *Schema) Validate(document any) []error {
+	return s.validate(document, "")
+}
+
+func (s *Schema) validate(value any, pointer string) []error {
+	errs := make([]error, 0)
+	switch s.Type {
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return append(errs, SchemaError{Pointer: pointer, Message: "expected boolean"})
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return append(errs, SchemaError{Pointer: pointer, Message: "expected string"})
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			errs = append(errs, SchemaError{
+				Pointer: pointer,
+				Message: fmt.Sprintf("expected one of: %s", strings.Join(s.Enum, ", ")),
+			})
+		}
+		if s.MinLength != nil && len([]rune(str)) < *s.MinLength {
+			errs = append(errs, SchemaError{
+				Pointer: pointer,
+				Message: fmt.Sprintf("expected at least %d character(s)", *s.MinLength),
+			})
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return append(errs, SchemaError{Pointer: pointer, Message: "expected integer"})
+		}
+		if s.Minimum != nil && number < float64(*s.Minimum) {
+			errs = append(errs, SchemaError{
+				Pointer: pointer,
+				Message: fmt.Sprintf("expected a minimum of %d", *s.Minimum),
+			})
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return append(errs, SchemaError{Pointer: pointer, Message: "expected array"})
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			errs = append(errs, SchemaError{
+				Pointer: pointer,
+				Message: fmt.Sprintf("expected at least %d item(s)", *s.MinItems),
+			})
+		}
+		for i, item := range items {
+			errs = append(errs, s.Items.validate(item, pointer+"/"+strconv.Itoa(i))...)
+		}
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return append(errs, SchemaError{Pointer: pointer, Message: "expected object"})
+		}
+		for _, name := range s.properties() {
+			property, found := object[name]
+			if !found {
+				if slices.Contains(s.Required, name) {
+					errs = append(errs, SchemaError{
+						Pointer: pointer + "/" + escapePointer(name),
+						Message: "missing required property",
+					})
+				}
+				continue
+			}
+			errs = append(errs, s.Properties[name].validate(property, pointer+"/"+escapePointer(name))...)
+		}
+		if s.AdditionalProperties != nil {
+			names := make([]string, 0, len(object))
+			for name := range object {
+				if _, found := s.Properties[name]; !found {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				errs = append(errs, s.AdditionalProperties.validate(object[name], pointer+"/"+escapePointer(name))...)
+			}
+		}
+	}
+	return errs
+}
+
+// properties returns the names of the properties, sorted to report the
+// errors in the same order whether the schema is generated or decoded.
+func (s *Schema) properties() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// escapePointer escapes a JSON Pointer reference token, see RFC 6901.
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
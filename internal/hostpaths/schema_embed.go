@@ -0,0 +1,24 @@
+//go:build tinygo
+
+package hostpaths
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// settingsSchemaFile is a copy of the settings.schema.json file, kept up to
+// date by TestSettingsSchemaFile.
+//
+//go:embed settings.schema.json
+var settingsSchemaFile []byte
+
+// loadSettingsSchema decodes the embedded JSON Schema of the settings.
+func loadSettingsSchema() *Schema {
+	schema := &Schema{}
+	if err := json.Unmarshal(settingsSchemaFile, schema); err != nil {
+		panic(fmt.Sprintf("invalid embedded settings schema: %v", err))
+	}
+	return schema
+}
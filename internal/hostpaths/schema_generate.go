@@ -0,0 +1,94 @@
+//go:build !tinygo
+
+package hostpaths
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// loadSettingsSchema generates the JSON Schema of the settings, see
+// generateSettingsSchema.
+func loadSettingsSchema() *Schema {
+	return generateSettingsSchema()
+}
+
+// generateSettingsSchema returns the JSON Schema of the settings, generated
+// from the Settings type: the properties are named after the `json` tags of
+// the fields, described by their `description` tags, and constrained by their
+// `jsonschema` tags, a comma separated list of `required`, `minLength=N`,
+// `minItems=N`, `minimum=N` and `enum=A|B`, the latter applying to the items
+// of the arrays. Pointer fields are optional values, maps are objects whose
+// values are described by `additionalProperties`.
+func generateSettingsSchema() *Schema {
+	schema := schemaFor(reflect.TypeOf(Settings{}))
+	schema.Dialect = schemaDialect
+	schema.Title = "hostpaths-psp settings"
+	return schema
+}
+
+func schemaFor(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaFor(t.Elem())}
+	case reflect.Struct:
+		schema := &Schema{
+			Type:       "object",
+			Properties: make(map[string]*Schema),
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+
+			property := schemaFor(field.Type)
+			property.Description = field.Tag.Get("description")
+			for _, option := range strings.Split(field.Tag.Get("jsonschema"), ",") {
+				key, value, _ := strings.Cut(option, "=")
+				switch key {
+				case "required":
+					schema.Required = append(schema.Required, name)
+				case "minLength":
+					property.MinLength = newInt(value)
+				case "minItems":
+					property.MinItems = newInt(value)
+				case "minimum":
+					property.Minimum = newInt(value)
+				case "enum":
+					enumProperty := property
+					if enumProperty.Items != nil {
+						// the values of the array items
+						enumProperty = enumProperty.Items
+					}
+					enumProperty.Enum = strings.Split(value, "|")
+				}
+			}
+			schema.Properties[name] = property
+		}
+		return schema
+	default:
+		panic(fmt.Sprintf("no JSON Schema type for %s", t))
+	}
+}
+
+func newInt(value string) *int {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		panic(fmt.Sprintf("invalid jsonschema tag value '%s'", value))
+	}
+	return &i
+}
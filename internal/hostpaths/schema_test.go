@@ -0,0 +1,144 @@
+package hostpaths
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestSettingsSchemaFile fails when the checked-in schemas don't match the
+// settings types, run it with -update to regenerate them: the one of the
+// policy, and its copy embedded by the TinyGo builds, see loadSettingsSchema.
+func TestSettingsSchemaFile(t *testing.T) {
+	actual, err := json.MarshalIndent(SettingsSchema(), "", "  ")
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	actual = append(actual, '\n')
+
+	for _, file := range []string{"../../settings.schema.json", "settings.schema.json"} {
+		if *update {
+			if err = os.WriteFile(file, actual, 0o644); err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+		}
+		expected, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Unexpected error %+v", err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("%s is out of date, regenerate it with `go test ./internal/hostpaths -run TestSettingsSchemaFile -update`", file)
+		}
+
+		decoded := &Schema{}
+		if err = json.Unmarshal(expected, decoded); err != nil {
+			t.Fatalf("Unexpected error %+v", err)
+		}
+		if !reflect.DeepEqual(decoded, SettingsSchema()) {
+			t.Errorf("%s doesn't decode to the generated schema", file)
+		}
+	}
+}
+
+func TestSettingsSchemaValidate(t *testing.T) {
+	for _, tcase := range []struct {
+		name     string
+		settings string
+		errors   []string
+	}{
+		{
+			name: "valid",
+			settings: `{
+				"allowedHostPaths": [{"pathPrefix": "/foo", "readOnly": true}],
+				"podSpecPaths": [{"group": "", "kind": "Rollout", "paths": ["spec.template.spec"]}],
+				"unknown": 1
+			}`,
+			errors: []string{},
+		},
+		{
+			name: "wrong types",
+			settings: `{
+				"allowedHostPaths": [
+					{"pathPrefix": "/foo", "readOnly": true},
+					{"pathPrefix": 1, "readOnly": "yes"}
+				],
+				"ratcheting": "true"
+			}`,
+			errors: []string{
+				"/allowedHostPaths/1/pathPrefix: expected string",
+				"/allowedHostPaths/1/readOnly: expected boolean",
+				"/ratcheting: expected boolean",
+			},
+		},
+		{
+			name:     "not one of the enum values",
+			settings: `{"privilegeGuard": "sometimes"}`,
+			errors:   []string{"/privilegeGuard: expected one of: never, hostPaths, always"},
+		},
+		{
+			name:     "not an array",
+			settings: `{"allowedHostPaths": {"pathPrefix": "/foo", "readOnly": true}}`,
+			errors:   []string{"/allowedHostPaths: expected array"},
+		},
+		{
+			name:     "not an object",
+			settings: `[]`,
+			errors:   []string{"/: expected object"},
+		},
+		{
+			name:     "constraints",
+			settings: `{"podSpecPaths": [{"kind": "", "paths": []}, {"group": "apps"}]}`,
+			errors: []string{
+				"/podSpecPaths/0/kind: expected at least 1 character(s)",
+				"/podSpecPaths/0/paths: expected at least 1 item(s)",
+				"/podSpecPaths/1/kind: missing required property",
+				"/podSpecPaths/1/paths: missing required property",
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			var document any
+			if err := json.Unmarshal([]byte(tcase.settings), &document); err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			errs := SettingsSchema().Validate(document)
+			if len(errs) != len(tcase.errors) {
+				t.Fatalf("Wanted errors %q, but got %q instead", tcase.errors, errs)
+			}
+			for i, err := range errs {
+				if err.Error() != tcase.errors[i] {
+					t.Errorf("Wanted error '%s', but got '%s' instead", tcase.errors[i], err.Error())
+				}
+			}
+		})
+	}
+}
+
+// TestQuestionsFile fails when the checked-in questions-ui.yml doesn't match
+// the settings types, run it with -update to regenerate it.
+func TestQuestionsFile(t *testing.T) {
+	actual := bytes.Buffer{}
+	if err := WriteQuestions(&actual); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	file := "../../questions-ui.yml"
+	if *update {
+		if err := os.WriteFile(file, actual.Bytes(), 0o644); err != nil {
+			t.Fatalf("Unexpected error %+v", err)
+		}
+	}
+	expected, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if !bytes.Equal(actual.Bytes(), expected) {
+		t.Errorf("%s is out of date, regenerate it with `go test ./internal/hostpaths -run TestQuestionsFile -update`", file)
+	}
+}
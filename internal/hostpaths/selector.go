@@ -0,0 +1,146 @@
+package hostpaths
+
+import (
+	"slices"
+)
+
+// Operators of the label selector requirements.
+const (
+	SelectorOpIn           = "In"
+	SelectorOpNotIn        = "NotIn"
+	SelectorOpExists       = "Exists"
+	SelectorOpDoesNotExist = "DoesNotExist"
+)
+
+// LabelSelector is a Kubernetes label selector. The requirements of
+// MatchLabels and MatchExpressions are ANDed, an empty selector matches all
+// the labels.
+type LabelSelector struct {
+	MatchLabels      map[string]string          `json:"matchLabels,omitempty" description:"Labels the pods must have, with the given values." label:"Match labels" tooltip:"Labels the pods must have."`
+	MatchExpressions []LabelSelectorRequirement `json:"matchExpressions,omitempty" description:"Requirements on the labels of the pods." label:"Match expressions" tooltip:"Requirements on the labels of the pods."`
+}
+
+// LabelSelectorRequirement is a requirement of a Kubernetes label selector.
+type LabelSelectorRequirement struct {
+	Key      string   `json:"key" jsonschema:"required,minLength=1" description:"Label the requirement applies to." label:"Key" tooltip:"Label the requirement applies to."`
+	Operator string   `json:"operator" jsonschema:"required,enum=In|NotIn|Exists|DoesNotExist" description:"Relationship between the label and the values." label:"Operator" tooltip:"Relationship between the label and the values."`
+	Values   []string `json:"values,omitempty" description:"Values of the label, required by the In and NotIn operators, forbidden by the others." label:"Values" tooltip:"Values of the label, only for the In and NotIn operators."`
+}
+
+// Matches returns whether the labels satisfy all the requirements of the
+// selector.
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+	for key, value := range s.MatchLabels {
+		if actual, found := labels[key]; !found || actual != value {
+			return false
+		}
+	}
+	for _, requirement := range s.MatchExpressions {
+		if !requirement.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r LabelSelectorRequirement) matches(labels map[string]string) bool {
+	value, found := labels[r.Key]
+	switch r.Operator {
+	case SelectorOpIn:
+		return found && slices.Contains(r.Values, value)
+	case SelectorOpNotIn:
+		return !found || !slices.Contains(r.Values, value)
+	case SelectorOpExists:
+		return found
+	case SelectorOpDoesNotExist:
+		return !found
+	default:
+		return false
+	}
+}
+
+// disjoint returns whether no labels can satisfy both selectors, because of
+// incompatible requirements on the same label. A nil selector matches all the
+// labels.
+func (s *LabelSelector) disjoint(other *LabelSelector) bool {
+	if s == nil || other == nil {
+		return false
+	}
+	byKey := make(map[string][]LabelSelectorRequirement)
+	for _, requirement := range append(s.requirements(), other.requirements()...) {
+		byKey[requirement.Key] = append(byKey[requirement.Key], requirement)
+	}
+	for _, requirements := range byKey {
+		if !satisfiable(requirements) {
+			return true
+		}
+	}
+	return false
+}
+
+// requirements returns all the requirements of the selector, MatchLabels
+// being In requirements with a single value.
+func (s *LabelSelector) requirements() []LabelSelectorRequirement {
+	requirements := make([]LabelSelectorRequirement, 0, len(s.MatchLabels)+len(s.MatchExpressions))
+	for key, value := range s.MatchLabels {
+		requirements = append(requirements, LabelSelectorRequirement{
+			Key:      key,
+			Operator: SelectorOpIn,
+			Values:   []string{value},
+		})
+	}
+	return append(requirements, s.MatchExpressions...)
+}
+
+// satisfiable returns whether a value of the label, or its absence, satisfies
+// all the given requirements on that label.
+func satisfiable(requirements []LabelSelectorRequirement) bool {
+	var allowed []string // nil when any value is allowed
+	excluded := make([]string, 0)
+	exists, absent := false, false
+	for _, requirement := range requirements {
+		switch requirement.Operator {
+		case SelectorOpIn:
+			exists = true
+			if allowed == nil {
+				allowed = slices.Clone(requirement.Values)
+			} else {
+				allowed = slices.DeleteFunc(allowed, func(value string) bool {
+					return !slices.Contains(requirement.Values, value)
+				})
+			}
+		case SelectorOpNotIn:
+			excluded = append(excluded, requirement.Values...)
+		case SelectorOpExists:
+			exists = true
+		case SelectorOpDoesNotExist:
+			absent = true
+		}
+	}
+	switch {
+	case exists && absent:
+		return false
+	case absent || allowed == nil:
+		return true
+	default:
+		return slices.ContainsFunc(allowed, func(value string) bool {
+			return !slices.Contains(excluded, value)
+		})
+	}
+}
+
+// validate returns the problems of the requirement not described by the
+// JSON Schema of the settings.
+func (r LabelSelectorRequirement) validate() string {
+	switch r.Operator {
+	case SelectorOpIn, SelectorOpNotIn:
+		if len(r.Values) == 0 {
+			return "values should not be empty for operator '" + r.Operator + "'"
+		}
+	default:
+		if len(r.Values) > 0 {
+			return "values should be empty for operator '" + r.Operator + "'"
+		}
+	}
+	return ""
+}
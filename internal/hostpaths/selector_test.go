@@ -0,0 +1,203 @@
+package hostpaths
+
+import (
+	"reflect"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"k8s-app": "cilium", "tier": "node"}
+	for _, tcase := range []struct {
+		name     string
+		selector LabelSelector
+		matches  bool
+	}{
+		{
+			name:     "empty selector",
+			selector: LabelSelector{},
+			matches:  true,
+		},
+		{
+			name:     "matching labels",
+			selector: LabelSelector{MatchLabels: map[string]string{"k8s-app": "cilium"}},
+			matches:  true,
+		},
+		{
+			name:     "label with another value",
+			selector: LabelSelector{MatchLabels: map[string]string{"k8s-app": "calico"}},
+			matches:  false,
+		},
+		{
+			name: "In",
+			selector: LabelSelector{MatchExpressions: []LabelSelectorRequirement{
+				{Key: "tier", Operator: SelectorOpIn, Values: []string{"node", "system"}},
+			}},
+			matches: true,
+		},
+		{
+			name: "NotIn",
+			selector: LabelSelector{MatchExpressions: []LabelSelectorRequirement{
+				{Key: "tier", Operator: SelectorOpNotIn, Values: []string{"node"}},
+			}},
+			matches: false,
+		},
+		{
+			name: "NotIn missing label",
+			selector: LabelSelector{MatchExpressions: []LabelSelectorRequirement{
+				{Key: "team", Operator: SelectorOpNotIn, Values: []string{"web"}},
+			}},
+			matches: true,
+		},
+		{
+			name: "Exists",
+			selector: LabelSelector{MatchExpressions: []LabelSelectorRequirement{
+				{Key: "k8s-app", Operator: SelectorOpExists},
+			}},
+			matches: true,
+		},
+		{
+			name: "DoesNotExist",
+			selector: LabelSelector{MatchExpressions: []LabelSelectorRequirement{
+				{Key: "k8s-app", Operator: SelectorOpDoesNotExist},
+			}},
+			matches: false,
+		},
+		{
+			name: "labels and expressions are ANDed",
+			selector: LabelSelector{
+				MatchLabels: map[string]string{"k8s-app": "cilium"},
+				MatchExpressions: []LabelSelectorRequirement{
+					{Key: "team", Operator: SelectorOpExists},
+				},
+			},
+			matches: false,
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			if matches := tcase.selector.Matches(labels); matches != tcase.matches {
+				t.Errorf("Wanted %t, but got %t instead", tcase.matches, matches)
+			}
+		})
+	}
+}
+
+func TestLabelSelectorDisjoint(t *testing.T) {
+	storage := &LabelSelector{MatchLabels: map[string]string{"team": "storage"}}
+	for _, tcase := range []struct {
+		name     string
+		a, b     *LabelSelector
+		disjoint bool
+	}{
+		{"all the labels", storage, nil, false},
+		{"same labels", storage, storage, false},
+		{"other labels", storage, &LabelSelector{MatchLabels: map[string]string{"app": "backup"}}, false},
+		{"other values", storage, &LabelSelector{MatchLabels: map[string]string{"team": "web"}}, true},
+		{
+			"excluded values",
+			storage,
+			&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "team", Operator: SelectorOpNotIn, Values: []string{"storage"}}}},
+			true,
+		},
+		{
+			"other excluded values",
+			storage,
+			&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "team", Operator: SelectorOpNotIn, Values: []string{"web"}}}},
+			false,
+		},
+		{
+			"common values",
+			&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "team", Operator: SelectorOpIn, Values: []string{"storage", "web"}}}},
+			&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "team", Operator: SelectorOpIn, Values: []string{"web", "ops"}}}},
+			false,
+		},
+		{
+			"missing label",
+			&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "team", Operator: SelectorOpExists}}},
+			&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "team", Operator: SelectorOpDoesNotExist}}},
+			true,
+		},
+		{
+			"missing excluded label",
+			&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "team", Operator: SelectorOpNotIn, Values: []string{"web"}}}},
+			&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "team", Operator: SelectorOpDoesNotExist}}},
+			false,
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			if disjoint := tcase.a.disjoint(tcase.b); disjoint != tcase.disjoint {
+				t.Errorf("Wanted %t, but got %t instead", tcase.disjoint, disjoint)
+			}
+			if disjoint := tcase.b.disjoint(tcase.a); disjoint != tcase.disjoint {
+				t.Errorf("Reversed, wanted %t, but got %t instead", tcase.disjoint, disjoint)
+			}
+		})
+	}
+}
+
+func TestValidateMountsSelector(t *testing.T) {
+	settings := Settings{AllowedHostPaths: []HostPath{
+		{PathPrefix: "/var/lib", ReadOnly: true},
+		{
+			PathPrefix: "/var/lib/cilium",
+			ReadOnly:   false,
+			Selector:   &LabelSelector{MatchLabels: map[string]string{"k8s-app": "cilium"}},
+		},
+	}}
+
+	for _, tcase := range []struct {
+		name     string
+		gvk      kubewarden_protocol.GroupVersionKind
+		object   string
+		messages []string
+	}{
+		{
+			name:     "pod template matching the selector",
+			gvk:      kubewarden_protocol.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+			object:   `{"metadata": {"labels": {"k8s-app": "other"}}, "spec": {"template": {"metadata": {"labels": {"k8s-app": "cilium"}}, "spec": {}}}}`,
+			messages: []string{},
+		},
+		{
+			name:     "pod matching the selector",
+			gvk:      kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			object:   `{"metadata": {"labels": {"k8s-app": "cilium"}}, "spec": {}}`,
+			messages: []string{},
+		},
+		{
+			name:   "pod not matching the selector",
+			gvk:    kubewarden_protocol.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			object: `{"metadata": {"labels": {"k8s-app": "calico"}}, "spec": {}}`,
+			messages: []string{
+				"hostPath '/var/lib/cilium' mounted as 'data' should be readOnly 'true'",
+			},
+		},
+		{
+			name:   "pod template without labels",
+			gvk:    kubewarden_protocol.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"},
+			object: `{"spec": {"jobTemplate": {"spec": {"template": {"spec": {}}}}}}`,
+			messages: []string{
+				"hostPath '/var/lib/cilium' mounted as 'data' should be readOnly 'true'",
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			podSpecs, err := ExtractPodSpecs(tcase.gvk, []byte(tcase.object), settings)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			mounts := []Mount{
+				{Volume: Volume{Name: "data", Path: "/var/lib/cilium"}, Container: "agent", Name: "data"},
+			}
+			violations := ValidateMounts(podSpecs[0], mounts, settings)
+			messages := make([]string, 0, len(violations))
+			for _, violation := range violations {
+				messages = append(messages, violation.Message)
+			}
+			if !reflect.DeepEqual(messages, tcase.messages) {
+				t.Errorf("Wanted %q, but got %q instead", tcase.messages, messages)
+			}
+		})
+	}
+}
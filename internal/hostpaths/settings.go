@@ -0,0 +1,266 @@
+// Package hostpaths holds the settings and the evaluation logic of the
+// hostpaths-psp policy, shared between the policy and its native tooling.
+package hostpaths
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kubewarden/gjson"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// The `description` and `jsonschema` tags of the settings types are used to
+// generate their JSON Schema, see SettingsSchema. The `label` and `tooltip`
+// tags are used to generate the questions-ui.yml file, see WriteQuestions.
+
+type HostPath struct {
+	PathPrefix string `json:"pathPrefix" jsonschema:"required" description:"Allows hostPath volumes to mount a path that begins with this prefix." label:"Path prefix" tooltip:"Prefix of the allowed host paths."`
+	ReadOnly   bool   `json:"readOnly" jsonschema:"required" description:"Whether the paths must be mounted read-only." label:"Read only" tooltip:"Indicates if the volume must be mounted read-only."`
+	// The readOnly attributes of the container roles override ReadOnly for
+	// the mounts of their containers. The native sidecars are long-running
+	// containers, they don't use the attribute of the init containers
+	InitContainersReadOnly      *bool `json:"initContainersReadOnly,omitempty" description:"Whether the paths must be mounted read-only by the init containers, readOnly when unset." label:"Init containers read only" tooltip:"Overrides readOnly for the init containers."`
+	SidecarContainersReadOnly   *bool `json:"sidecarContainersReadOnly,omitempty" description:"Whether the paths must be mounted read-only by the native sidecars, init containers with the Always restartPolicy, readOnly when unset." label:"Sidecar containers read only" tooltip:"Overrides readOnly for the native sidecar containers."`
+	EphemeralContainersReadOnly *bool `json:"ephemeralContainersReadOnly,omitempty" description:"Whether the paths must be mounted read-only by the ephemeral containers, readOnly when unset." label:"Ephemeral containers read only" tooltip:"Overrides readOnly for the ephemeral containers."`
+	// AllowedKinds restricts the entry to the objects of the given kinds
+	AllowedKinds []string `json:"allowedKinds,omitempty" description:"Kinds of the objects the entry applies to, like DaemonSet, all of them when empty. Pods also match the kind of the controller owning them." label:"Allowed kinds" tooltip:"Kinds allowed to mount the paths, all of them when empty."`
+	// AllowedRuntimeClasses restricts the entry to the pods running with
+	// the given RuntimeClasses, like the sandboxed ones
+	AllowedRuntimeClasses []string `json:"allowedRuntimeClasses,omitempty" description:"RuntimeClasses of the pods the entry applies to, like the sandboxed gVisor or Kata ones, all of them when empty. An empty name stands for the default RuntimeClass." label:"Allowed RuntimeClasses" tooltip:"RuntimeClasses allowed to mount the paths, all of them when empty."`
+	// AllowedImages restricts the entry to the containers running images
+	// matching one of the patterns
+	AllowedImages []string `json:"allowedImages,omitempty" description:"Patterns of the images of the containers the entry applies to, all of them when empty: repository globs with an optional tag and digest, like registry.internal/observability/*@sha256:* requiring a digest." label:"Allowed images" tooltip:"Images allowed to mount the paths, all of them when empty."`
+	// Selector restricts the entry to the pods whose labels match it
+	Selector *LabelSelector `json:"selector,omitempty" description:"Label selector restricting the entry to the matching pods, all of them when empty. Pod templates are matched by their own labels."`
+	// RequireNodeLabels restricts the mounts of the paths to the pods
+	// pinned to the nodes with the given labels
+	RequireNodeLabels map[string]string `json:"requireNodeLabels,omitempty" description:"Node labels the pods mounting the paths must be pinned to, with their nodeSelector or a required node affinity." label:"Require node labels" tooltip:"Labels of the dedicated nodes the pods mounting the paths must run on."`
+	// The identity requirements are enforced on the writable mounts of
+	// the paths, against the pod and container security contexts
+	RequireRunAsNonRoot   bool   `json:"requireRunAsNonRoot,omitempty" description:"Require the containers mounting the paths read-write to set runAsNonRoot." label:"Require runAsNonRoot" tooltip:"Writable mounts require runAsNonRoot containers."`
+	RequireHostUsersFalse bool   `json:"requireHostUsersFalse,omitempty" description:"Require the pods mounting the paths read-write to set hostUsers to false, running inside of a user namespace." label:"Require hostUsers false" tooltip:"Writable mounts require pods inside of a user namespace."`
+	MinRunAsUser          *int64 `json:"minRunAsUser,omitempty" jsonschema:"minimum=0" description:"Lowest runAsUser of the containers mounting the paths read-write." label:"Minimum runAsUser" tooltip:"Lowest user ID of the containers with writable mounts."`
+	MaxRunAsUser          *int64 `json:"maxRunAsUser,omitempty" jsonschema:"minimum=0" description:"Highest runAsUser of the containers mounting the paths read-write." label:"Maximum runAsUser" tooltip:"Highest user ID of the containers with writable mounts."`
+	// The confinement requirements are enforced on all the mounts of the
+	// paths, one of the listed values must be used
+	SELinuxTypes     []string `json:"seLinuxTypes,omitempty" description:"SELinux types, one of which the containers mounting the paths must run with." label:"SELinux types" tooltip:"SELinux types allowed for the containers mounting the paths."`
+	AppArmorProfiles []string `json:"appArmorProfiles,omitempty" description:"AppArmor profiles, one of which the containers mounting the paths must run with: runtime/default, localhost/<profile> or unconfined." label:"AppArmor profiles" tooltip:"AppArmor profiles allowed for the containers mounting the paths."`
+}
+
+// PodSpecPath maps a kind to the gjson paths of the pod specs embedded
+// inside of its objects. It allows the policy to validate kinds other than Pods
+// and the workload resources, like the ones defined by CRDs.
+type PodSpecPath struct {
+	Group string `json:"group" description:"API group of the kind, empty for the core group." label:"Group" tooltip:"API group of the kind. Leave it empty for the core group."`
+	// Version is optional, an empty value matches all the versions of
+	// the kind
+	Version string   `json:"version,omitempty" description:"Version of the kind, empty to match all the versions." label:"Version" tooltip:"Version of the kind. Leave it empty to match all the versions."`
+	Kind    string   `json:"kind" jsonschema:"required,minLength=1" description:"Kind embedding the pod specs." label:"Kind" tooltip:"Kind embedding the pod specs."`
+	Paths   []string `json:"paths" jsonschema:"required,minItems=1" description:"gjson paths of the embedded pod specs." label:"Paths" tooltip:"gjson paths of the embedded pod specs."`
+}
+
+type Settings struct {
+	AllowedHostPaths []HostPath    `json:"allowedHostPaths" description:"Host paths allowed to be used by hostPath volumes, an empty list allows all of them." label:"Allow host path" tooltip:"A list of host paths that are allowed to be used by hostPath volumes."`
+	PodSpecPaths     []PodSpecPath `json:"podSpecPaths,omitempty" description:"Paths of the pod specs embedded inside of other kinds, like the ones defined by CRDs." label:"Pod spec paths" tooltip:"Paths of the pod specs embedded inside of custom resources."`
+	// CheckPersistentVolumeClaims enables the validation of the
+	// PersistentVolumes bound to the claims used by the pods. It requires
+	// the policy to be granted access to the PersistentVolumeClaim and
+	// PersistentVolume resources
+	CheckPersistentVolumeClaims bool `json:"checkPersistentVolumeClaims,omitempty" description:"Validate the hostPath and local PersistentVolumes bound to the claims used by the pods. Requires to grant the policy access to the PersistentVolumeClaim and PersistentVolume resources." label:"Check PersistentVolumeClaims" tooltip:"Validate hostPath and local PersistentVolumes used by the pods."`
+	// PersistentVolumeHostPathTypes restricts the types of the hostPath
+	// PersistentVolumes, the empty type skipping the checks of the node
+	PersistentVolumeHostPathTypes []string `json:"persistentVolumeHostPathTypes,omitempty" jsonschema:"enum=|DirectoryOrCreate|Directory|FileOrCreate|File|Socket|CharDevice|BlockDevice" description:"Types allowed for the hostPath PersistentVolumes, an empty list allows all of them. List the empty type to allow the volumes without type, which are not checked by the kubelet." label:"PersistentVolume hostPath types" tooltip:"Types allowed for the hostPath PersistentVolumes. Leave it empty to allow all of them."`
+	// ProtectReadOnlySubPaths rejects the writable mounts of the paths
+	// containing read-only AllowedHostPaths entries, which would give write
+	// access to them
+	ProtectReadOnlySubPaths bool `json:"protectReadOnlySubPaths,omitempty" description:"Reject the writable mounts of the host paths containing read-only allowedHostPaths entries, which would give write access to them." label:"Protect read-only sub-paths" tooltip:"Reject writable mounts containing read-only allowed paths."`
+	// PrivilegeGuard rejects the privileged containers and the ones adding
+	// DangerousCapabilities, which can mount any path of the node. See
+	// PrivilegeGuardNever and friends for the values
+	PrivilegeGuard         string   `json:"privilegeGuard,omitempty" jsonschema:"enum=never|hostPaths|always" description:"Reject the privileged containers and the ones adding dangerous capabilities, which can mount any host path: never, only inside of the pods using hostPath volumes, or always." label:"Privilege guard" tooltip:"Reject privileged containers, which can mount any host path."`
+	PrivilegeGuardWarnOnly bool     `json:"privilegeGuardWarnOnly,omitempty" description:"Log the containers found by the privilege guard as warnings instead of rejecting them." label:"Privilege guard warn only" tooltip:"Only log the containers found by the privilege guard."`
+	DangerousCapabilities  []string `json:"dangerousCapabilities,omitempty" description:"Capabilities rejected by the privilege guard, SYS_ADMIN when empty. The CAP_ prefix is optional." label:"Dangerous capabilities" tooltip:"Capabilities rejected by the privilege guard, SYS_ADMIN when empty."`
+	// Ratcheting only enforces the violations of UPDATE requests that the
+	// old object didn't already have, the others are logged as warnings
+	Ratcheting bool `json:"ratcheting,omitempty" description:"Only reject the violations of UPDATE requests that the old object didn't already have, the pre-existing ones are logged as warnings." label:"Ratcheting" tooltip:"Only reject the violations introduced by UPDATE requests."`
+}
+
+// builtinPodSpecPaths holds the pod spec paths of the kinds that are always
+// validated, in addition to Pods and the workload resources.
+var builtinPodSpecPaths = []PodSpecPath{
+	{
+		Group:   "",
+		Version: "v1",
+		Kind:    "PodTemplate",
+		Paths:   []string{"template.spec"},
+	},
+}
+
+// PodSpecPathsFor returns the gjson paths of the pod specs embedded inside of
+// the objects of the given kind. Paths defined inside of the settings take
+// precedence over the builtin ones.
+func (s *Settings) PodSpecPathsFor(gvk kubewarden_protocol.GroupVersionKind) []string {
+	for _, podSpecPaths := range [][]PodSpecPath{s.PodSpecPaths, builtinPodSpecPaths} {
+		for _, podSpecPath := range podSpecPaths {
+			if podSpecPath.Group == gvk.Group && podSpecPath.Kind == gvk.Kind &&
+				(podSpecPath.Version == "" || podSpecPath.Version == gvk.Version) {
+				return podSpecPath.Paths
+			}
+		}
+	}
+	return nil
+}
+
+// Builds a new Settings instance starting from a validation
+// request payload:
+//
+//	{
+//	   "request": ...,
+//	   "settings": {
+//	      "allowedHostPaths": [
+//	      	{
+//	      	  "pathPrefix": "foo",
+//	      	  "readOnly": true,
+//	         }
+//	      ],
+//	      "podSpecPaths": [
+//	      	{
+//	      	  "group": "argoproj.io",
+//	      	  "kind": "Rollout",
+//	      	  "paths": ["spec.template.spec"]
+//	      	}
+//	      ],
+//	      "checkPersistentVolumeClaims": true,
+//	      "protectReadOnlySubPaths": true,
+//	      "privilegeGuard": "hostPaths",
+//	      "dangerousCapabilities": ["SYS_ADMIN"],
+//	      "ratcheting": true
+//	   }
+//	}
+func NewSettingsFromValidationReq(payload []byte) (Settings, error) {
+	return newSettings([]byte(gjson.GetBytes(payload, "settings").Raw))
+}
+
+// Builds a new Settings instance starting from a Settings
+// payload:
+//
+//	{
+//	  "allowedHostPaths": [
+//	  	{
+//	  	  "pathPrefix": "foo",
+//	  	  "readOnly": true,
+//	     }
+//	  ],
+//	  "podSpecPaths": [
+//	  	{
+//	  	  "group": "argoproj.io",
+//	  	  "kind": "Rollout",
+//	  	  "paths": ["spec.template.spec"]
+//	  	}
+//	  ],
+//	  "checkPersistentVolumeClaims": true,
+//	  "protectReadOnlySubPaths": true,
+//	  "privilegeGuard": "hostPaths",
+//	  "dangerousCapabilities": ["SYS_ADMIN"],
+//	  "ratcheting": true
+//	}
+func NewSettingsFromValidateSettingsPayload(payload []byte) (Settings, error) {
+	return newSettings(payload)
+}
+
+// newSettings validates the payload against the JSON Schema of the settings,
+// then decodes it. Missing and null payloads are empty settings.
+func newSettings(payload []byte) (Settings, error) {
+	settings := Settings{AllowedHostPaths: make([]HostPath, 0)}
+	if len(bytes.TrimSpace(payload)) == 0 || string(bytes.TrimSpace(payload)) == "null" {
+		return settings, nil
+	}
+
+	var document any
+	if err := json.Unmarshal(payload, &document); err != nil {
+		return settings, fmt.Errorf("cannot parse settings: %w", err)
+	}
+	if errs := SettingsSchema().Validate(document); len(errs) > 0 {
+		messages := make([]string, 0, len(errs))
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		return settings, errors.New(strings.Join(messages, "; "))
+	}
+
+	if err := json.Unmarshal(payload, &settings); err != nil {
+		return settings, fmt.Errorf("cannot parse settings: %w", err)
+	}
+	if settings.AllowedHostPaths == nil {
+		settings.AllowedHostPaths = make([]HostPath, 0)
+	}
+
+	// constraints between fields, not described by the schema
+	messages := make([]string, 0)
+	for i, hostPath := range settings.AllowedHostPaths {
+		for j, pattern := range hostPath.AllowedImages {
+			if message := validateImagePattern(pattern); message != "" {
+				messages = append(messages, SchemaError{
+					Pointer: fmt.Sprintf("/allowedHostPaths/%d/allowedImages/%d", i, j),
+					Message: message,
+				}.Error())
+			}
+		}
+		if hostPath.Selector != nil {
+			for j, requirement := range hostPath.Selector.MatchExpressions {
+				if message := requirement.validate(); message != "" {
+					messages = append(messages, SchemaError{
+						Pointer: fmt.Sprintf("/allowedHostPaths/%d/selector/matchExpressions/%d", i, j),
+						Message: message,
+					}.Error())
+				}
+			}
+		}
+		if hostPath.MinRunAsUser != nil && hostPath.MaxRunAsUser != nil && *hostPath.MinRunAsUser > *hostPath.MaxRunAsUser {
+			messages = append(messages, SchemaError{
+				Pointer: fmt.Sprintf("/allowedHostPaths/%d", i),
+				Message: "minRunAsUser should not be greater than maxRunAsUser",
+			}.Error())
+		}
+		for j, previous := range settings.AllowedHostPaths[:i] {
+			if hostPath.overlaps(previous) {
+				messages = append(messages, SchemaError{
+					Pointer: fmt.Sprintf("/allowedHostPaths/%d/pathPrefix", i),
+					Message: fmt.Sprintf("'%s' is already listed by /allowedHostPaths/%d for the same workloads, "+
+						"the entries sharing a pathPrefix must be restricted to distinct allowedKinds, "+
+						"allowedRuntimeClasses, allowedImages or selector labels",
+						hostPath.PathPrefix, j),
+				}.Error())
+				break
+			}
+		}
+	}
+	if len(messages) > 0 {
+		return settings, errors.New(strings.Join(messages, "; "))
+	}
+	return settings, nil
+}
+
+func (s *Settings) Valid() bool {
+	// each entry of allowedHostPaths needs to have 1 pathPrefix and 1 readOnly,
+	// which is checked against the JSON Schema when parsing
+	return true
+}
+
+// ValidateSettingsPayload builds a new Settings instance from a Settings
+// payload and validates it. This is the validation performed by the policy
+// `validate_settings` function.
+func ValidateSettingsPayload(payload []byte) (Settings, error) {
+	settings, err := NewSettingsFromValidateSettingsPayload(payload)
+	if err != nil {
+		return settings, err
+	}
+	if !settings.Valid() {
+		return settings, errors.New("Provided settings are not valid")
+	}
+	return settings, nil
+}
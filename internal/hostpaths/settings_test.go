@@ -0,0 +1,465 @@
+package hostpaths
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsingSettingsWithAllValuesProvidedFromValidationReq(t *testing.T) {
+	request := `
+	{
+		"request": "doesn't matter here",
+		"settings": {
+			"allowedHostPaths": [
+				{
+					"pathPrefix": "/foo",
+					"readOnly": true
+				},
+				{
+					"pathPrefix": "/bar",
+					"readOnly": false
+				}
+			]
+		}
+	}
+	`
+	rawRequest := []byte(request)
+
+	settings, err := NewSettingsFromValidationReq(rawRequest)
+	if err != nil {
+		t.Errorf("Unexpected error %+v", err)
+	}
+
+	if settings.AllowedHostPaths[0].PathPrefix != "/foo" &&
+		settings.AllowedHostPaths[0].ReadOnly != true &&
+		settings.AllowedHostPaths[1].PathPrefix != "/bar" &&
+		settings.AllowedHostPaths[1].ReadOnly != false {
+		t.Errorf("Missing value")
+	}
+}
+
+func TestParsingSettingsWithNoValueProvided(t *testing.T) {
+	request := `
+	{
+		"request": "doesn't matter here",
+		"settings": {
+		}
+	}
+	`
+	rawRequest := []byte(request)
+
+	settings, err := NewSettingsFromValidationReq(rawRequest)
+	if err != nil {
+		t.Errorf("Unexpected error %+v", err)
+	}
+
+	if len(settings.AllowedHostPaths) != 0 {
+		t.Errorf("Expected AllowedHostPaths to be empty")
+	}
+}
+
+func TestParsingSettingsWithEntriesMissing(t *testing.T) {
+	for _, tcase := range []struct {
+		name    string
+		request string
+		error   string
+	}{
+		{
+			name: "missing pathPrefix",
+			request: `
+			{
+				"request": "doesn't matter here",
+				"settings": {
+					"allowedHostPaths": [
+						{
+							"readOnly": true
+						}
+					]
+				}
+			}
+			`,
+			error: "/allowedHostPaths/0/pathPrefix: missing required property",
+		},
+		{
+			name: "missing readOnly",
+			request: `
+			{
+				"request": "doesn't matter here",
+				"settings": {
+					"allowedHostPaths": [
+						{
+							"readOnly": true
+						},
+						{
+							"pathPrefix": "/foo"
+						}
+					]
+				}
+			}
+			`,
+			error: "/allowedHostPaths/0/pathPrefix: missing required property; " +
+				"/allowedHostPaths/1/readOnly: missing required property",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			rawRequest := []byte(tcase.request)
+
+			_, err := NewSettingsFromValidationReq(rawRequest)
+			if err == nil {
+				t.Errorf("Wanted error, but no error was found")
+			}
+			if err.Error() != tcase.error {
+				t.Errorf("Wanted error '%s', but got '%s' instead",
+					tcase.error, err.Error())
+			}
+		})
+	}
+}
+
+func TestEmptySettingsAreValid(t *testing.T) {
+	request := `
+	{
+		"request": "doesn't matter here",
+		"settings": {
+		}
+	}
+	`
+	rawRequest := []byte(request)
+
+	settings, err := NewSettingsFromValidateSettingsPayload(rawRequest)
+	if err != nil {
+		t.Errorf("Unexpected error %+v", err)
+	}
+
+	if !settings.Valid() {
+		t.Errorf("Settings are reported as not valid")
+	}
+}
+
+func TestParsingSettingsWithPodSpecPaths(t *testing.T) {
+	request := `
+	{
+		"allowedHostPaths": [
+			{
+				"pathPrefix": "/foo",
+				"readOnly": true
+			}
+		],
+		"podSpecPaths": [
+			{
+				"group": "argoproj.io",
+				"kind": "Rollout",
+				"paths": ["spec.template.spec"]
+			}
+		]
+	}
+	`
+	rawRequest := []byte(request)
+
+	settings, err := NewSettingsFromValidateSettingsPayload(rawRequest)
+	if err != nil {
+		t.Errorf("Unexpected error %+v", err)
+	}
+
+	if len(settings.PodSpecPaths) != 1 ||
+		settings.PodSpecPaths[0].Group != "argoproj.io" ||
+		settings.PodSpecPaths[0].Kind != "Rollout" ||
+		settings.PodSpecPaths[0].Paths[0] != "spec.template.spec" {
+		t.Errorf("Unexpected podSpecPaths %+v", settings.PodSpecPaths)
+	}
+}
+
+func TestParsingSettingsWithInvalidPodSpecPaths(t *testing.T) {
+	for _, tcase := range []struct {
+		name    string
+		request string
+		error   string
+	}{
+		{
+			name: "missing kind and paths",
+			request: `
+			{
+				"podSpecPaths": [
+					{
+						"group": "argoproj.io",
+						"paths": ["spec.template.spec"]
+					},
+					{
+						"group": "argoproj.io",
+						"kind": "Rollout"
+					}
+				]
+			}
+			`,
+			error: "/podSpecPaths/0/kind: missing required property; " +
+				"/podSpecPaths/1/paths: missing required property",
+		},
+		{
+			name: "missing readOnly and kind",
+			request: `
+			{
+				"allowedHostPaths": [
+					{
+						"pathPrefix": "/foo"
+					}
+				],
+				"podSpecPaths": [
+					{
+						"paths": ["spec.template.spec"]
+					}
+				]
+			}
+			`,
+			error: "/allowedHostPaths/0/readOnly: missing required property; " +
+				"/podSpecPaths/0/kind: missing required property",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			_, err := NewSettingsFromValidateSettingsPayload([]byte(tcase.request))
+			if err == nil {
+				t.Fatalf("Wanted error, but no error was found")
+			}
+			if err.Error() != tcase.error {
+				t.Errorf("Wanted error '%s', but got '%s' instead",
+					tcase.error, err.Error())
+			}
+		})
+	}
+}
+
+func TestParsingSettingsWithCheckPersistentVolumeClaims(t *testing.T) {
+	for _, tcase := range []struct {
+		name     string
+		request  string
+		expected bool
+		error    string
+	}{
+		{
+			name:     "enabled",
+			request:  `{"checkPersistentVolumeClaims": true}`,
+			expected: true,
+		},
+		{
+			name:     "not set",
+			request:  `{}`,
+			expected: false,
+		},
+		{
+			name:    "not a boolean",
+			request: `{"checkPersistentVolumeClaims": "yes"}`,
+			error:   "/checkPersistentVolumeClaims: expected boolean",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			settings, err := NewSettingsFromValidateSettingsPayload([]byte(tcase.request))
+			if tcase.error != "" {
+				if err == nil || err.Error() != tcase.error {
+					t.Fatalf("Wanted error '%s', but got '%v' instead", tcase.error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+			if settings.CheckPersistentVolumeClaims != tcase.expected {
+				t.Errorf("Wanted checkPersistentVolumeClaims '%t', but got '%t' instead",
+					tcase.expected, settings.CheckPersistentVolumeClaims)
+			}
+		})
+	}
+}
+
+func TestParsingSettingsWithRatcheting(t *testing.T) {
+	settings, err := NewSettingsFromValidateSettingsPayload([]byte(`{"ratcheting": true}`))
+	if err != nil {
+		t.Errorf("Unexpected error %+v", err)
+	}
+	if !settings.Ratcheting {
+		t.Errorf("Expected ratcheting to be enabled")
+	}
+
+	_, err = NewSettingsFromValidateSettingsPayload([]byte(`{"ratcheting": 1}`))
+	if err == nil || err.Error() != "/ratcheting: expected boolean" {
+		t.Errorf("Wanted error '/ratcheting: expected boolean', but got '%v' instead", err)
+	}
+}
+
+func TestParsingSettingsWithRunAsUserRange(t *testing.T) {
+	settings, err := NewSettingsFromValidateSettingsPayload([]byte(`{
+		"allowedHostPaths": [{"pathPrefix": "/data", "readOnly": false, "minRunAsUser": 1000, "maxRunAsUser": 2000}]
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if *settings.AllowedHostPaths[0].MinRunAsUser != 1000 || *settings.AllowedHostPaths[0].MaxRunAsUser != 2000 {
+		t.Errorf("Unexpected allowedHostPaths %+v", settings.AllowedHostPaths)
+	}
+
+	for _, tcase := range []struct {
+		request string
+		error   string
+	}{
+		{
+			request: `{"allowedHostPaths": [{"pathPrefix": "/data", "readOnly": false, "minRunAsUser": 2000, "maxRunAsUser": 1000}]}`,
+			error:   "/allowedHostPaths/0: minRunAsUser should not be greater than maxRunAsUser",
+		},
+		{
+			request: `{"allowedHostPaths": [{"pathPrefix": "/data", "readOnly": false, "minRunAsUser": -1, "maxRunAsUser": 1.5}]}`,
+			error:   "/allowedHostPaths/0/maxRunAsUser: expected integer; /allowedHostPaths/0/minRunAsUser: expected a minimum of 0",
+		},
+	} {
+		_, err := NewSettingsFromValidateSettingsPayload([]byte(tcase.request))
+		if err == nil || err.Error() != tcase.error {
+			t.Errorf("Wanted error '%s', but got '%v' instead", tcase.error, err)
+		}
+	}
+}
+
+func TestParsingSettingsWithSelector(t *testing.T) {
+	settings, err := NewSettingsFromValidateSettingsPayload([]byte(`{
+		"allowedHostPaths": [{
+			"pathPrefix": "/var/lib/cilium",
+			"readOnly": false,
+			"selector": {
+				"matchLabels": {"k8s-app": "cilium"},
+				"matchExpressions": [{"key": "tier", "operator": "In", "values": ["node"]}]
+			}
+		}]
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	selector := settings.AllowedHostPaths[0].Selector
+	if selector == nil || selector.MatchLabels["k8s-app"] != "cilium" || len(selector.MatchExpressions) != 1 {
+		t.Errorf("Unexpected allowedHostPaths %+v", settings.AllowedHostPaths)
+	}
+
+	for _, tcase := range []struct {
+		request string
+		error   string
+	}{
+		{
+			request: `{"allowedHostPaths": [{"pathPrefix": "/data", "readOnly": false, "selector": {"matchLabels": {"app": 1}}}]}`,
+			error:   "/allowedHostPaths/0/selector/matchLabels/app: expected string",
+		},
+		{
+			request: `{"allowedHostPaths": [{"pathPrefix": "/data", "readOnly": false, "selector": {"matchExpressions": [{"key": "app", "operator": "Equals"}]}}]}`,
+			error:   "/allowedHostPaths/0/selector/matchExpressions/0/operator: expected one of: In, NotIn, Exists, DoesNotExist",
+		},
+		{
+			request: `{"allowedHostPaths": [{"pathPrefix": "/data", "readOnly": false, "selector": {"matchExpressions": [{"key": "app", "operator": "In"}]}}]}`,
+			error:   "/allowedHostPaths/0/selector/matchExpressions/0: values should not be empty for operator 'In'",
+		},
+		{
+			request: `{"allowedHostPaths": [{"pathPrefix": "/data", "readOnly": false, "selector": {"matchExpressions": [{"key": "app", "operator": "Exists", "values": ["a"]}]}}]}`,
+			error:   "/allowedHostPaths/0/selector/matchExpressions/0: values should be empty for operator 'Exists'",
+		},
+	} {
+		_, err := NewSettingsFromValidateSettingsPayload([]byte(tcase.request))
+		if err == nil || err.Error() != tcase.error {
+			t.Errorf("Wanted error '%s', but got '%v' instead", tcase.error, err)
+		}
+	}
+}
+
+func TestParsingSettingsWithInvalidImagePattern(t *testing.T) {
+	_, err := NewSettingsFromValidateSettingsPayload([]byte(`{
+		"allowedHostPaths": [{"pathPrefix": "/var/log", "readOnly": true, "allowedImages": ["registry.internal/[fluent-bit"]}]
+	}`))
+	expected := "/allowedHostPaths/0/allowedImages/0: invalid image pattern 'registry.internal/[fluent-bit'"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Wanted error '%s', but got '%v' instead", expected, err)
+	}
+}
+
+func TestParsingSettingsWithPersistentVolumeHostPathTypes(t *testing.T) {
+	settings, err := NewSettingsFromValidateSettingsPayload([]byte(`{
+		"persistentVolumeHostPathTypes": ["", "Directory"]
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if !reflect.DeepEqual(settings.PersistentVolumeHostPathTypes, []string{"", "Directory"}) {
+		t.Errorf("Unexpected persistentVolumeHostPathTypes %q", settings.PersistentVolumeHostPathTypes)
+	}
+
+	_, err = NewSettingsFromValidateSettingsPayload([]byte(`{
+		"persistentVolumeHostPathTypes": ["directory"]
+	}`))
+	expected := "/persistentVolumeHostPathTypes/0: expected one of: , DirectoryOrCreate, Directory, FileOrCreate, File, Socket, CharDevice, BlockDevice"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Wanted error '%s', but got '%v' instead", expected, err)
+	}
+}
+
+func TestParsingSettingsWithSamePathPrefix(t *testing.T) {
+	for _, tcase := range []struct {
+		name  string
+		paths string
+		error string
+	}{
+		{
+			name:  "restricted entry overriding the unrestricted one",
+			paths: `[{"pathPrefix": "/data", "readOnly": false, "allowedKinds": ["DaemonSet"]}, {"pathPrefix": "/data", "readOnly": true}]`,
+		},
+		{
+			name:  "entries restricted to distinct kinds",
+			paths: `[{"pathPrefix": "/data", "readOnly": false, "allowedKinds": ["DaemonSet"]}, {"pathPrefix": "/data/", "readOnly": true, "allowedKinds": ["Job"]}]`,
+		},
+		{
+			name:  "entries restricted to distinct RuntimeClasses",
+			paths: `[{"pathPrefix": "/data", "readOnly": false, "allowedRuntimeClasses": ["gvisor"]}, {"pathPrefix": "/data", "readOnly": true, "allowedRuntimeClasses": ["runc"]}]`,
+		},
+		{
+			name:  "unrestricted entries",
+			paths: `[{"pathPrefix": "/data", "readOnly": false}, {"pathPrefix": "/var", "readOnly": true}, {"pathPrefix": "/data/", "readOnly": true}]`,
+			error: "/allowedHostPaths/2/pathPrefix: '/data/' is already listed by /allowedHostPaths/0 for the same workloads, " +
+				"the entries sharing a pathPrefix must be restricted to distinct allowedKinds, allowedRuntimeClasses, allowedImages or selector labels",
+		},
+		{
+			name:  "entries restricted to overlapping kinds",
+			paths: `[{"pathPrefix": "/data", "readOnly": false, "allowedKinds": ["DaemonSet", "Job"]}, {"pathPrefix": "/data", "readOnly": true, "allowedKinds": ["Job"]}]`,
+			error: "/allowedHostPaths/1/pathPrefix: '/data' is already listed by /allowedHostPaths/0 for the same workloads, " +
+				"the entries sharing a pathPrefix must be restricted to distinct allowedKinds, allowedRuntimeClasses, allowedImages or selector labels",
+		},
+		{
+			name:  "entries restricted to distinct images",
+			paths: `[{"pathPrefix": "/data", "readOnly": false, "allowedImages": ["nginx"]}, {"pathPrefix": "/data", "readOnly": true, "allowedImages": ["redis"]}]`,
+		},
+		{
+			name:  "entries restricted to overlapping images",
+			paths: `[{"pathPrefix": "/data", "readOnly": false, "allowedImages": ["registry.example.com/*"]}, {"pathPrefix": "/data", "readOnly": true, "allowedImages": ["registry.example.com/agent:1.*"]}]`,
+			error: "/allowedHostPaths/1/pathPrefix: '/data' is already listed by /allowedHostPaths/0 for the same workloads, " +
+				"the entries sharing a pathPrefix must be restricted to distinct allowedKinds, allowedRuntimeClasses, allowedImages or selector labels",
+		},
+		{
+			name: "entries selecting distinct labels",
+			paths: `[{"pathPrefix": "/data", "readOnly": false, "selector": {"matchLabels": {"team": "storage"}}}, ` +
+				`{"pathPrefix": "/data", "readOnly": true, "selector": {"matchExpressions": [{"key": "team", "operator": "NotIn", "values": ["storage"]}]}}]`,
+		},
+		{
+			name: "entries selecting overlapping labels",
+			paths: `[{"pathPrefix": "/data", "readOnly": false, "selector": {"matchLabels": {"team": "storage"}}}, ` +
+				`{"pathPrefix": "/data", "readOnly": true, "selector": {"matchLabels": {"app": "backup"}}}]`,
+			error: "/allowedHostPaths/1/pathPrefix: '/data' is already listed by /allowedHostPaths/0 for the same workloads, " +
+				"the entries sharing a pathPrefix must be restricted to distinct allowedKinds, allowedRuntimeClasses, allowedImages or selector labels",
+		},
+		{
+			name:  "identical entries",
+			paths: `[{"pathPrefix": "/data", "readOnly": true}, {"pathPrefix": "/data/", "readOnly": true}]`,
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			_, err := NewSettingsFromValidateSettingsPayload([]byte(`{"allowedHostPaths": ` + tcase.paths + `}`))
+			if tcase.error == "" {
+				if err != nil {
+					t.Errorf("Unexpected error %+v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tcase.error {
+				t.Errorf("Wanted error '%s', but got '%v' instead", tcase.error, err)
+			}
+		})
+	}
+}
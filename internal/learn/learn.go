@@ -0,0 +1,363 @@
+// Package learn computes the allowedHostPaths settings of the hostpaths-psp
+// policy covering the hostPath volumes used by existing workloads.
+package learn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+	"github.com/kubewarden/go-policy-template/internal/scan"
+)
+
+// Usage is a hostPath volume mounted by a workload.
+type Usage struct {
+	Path     string
+	ReadOnly bool
+	// Workload identifies the object mounting the path, and its file
+	Workload string
+}
+
+// Collect returns the hostPath usages of all the workloads found inside of
+// the given files and directories, see scan.Walk. The podSpecPaths of the
+// given settings are used to find the pod specs of custom kinds.
+func Collect(paths []string, settings hostpaths.Settings) ([]Usage, error) {
+	usages := make([]Usage, 0)
+	err := scan.Walk(paths, func(file, _ string, raw json.RawMessage) error {
+		object, gvk, err := scan.ParseObject(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if !hostpaths.HasPodSpecs(gvk, settings) {
+			return nil
+		}
+		podSpecs, err := hostpaths.ExtractPodSpecs(gvk, raw, settings)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", file, object, err)
+		}
+
+		workload := fmt.Sprintf("%s (%s)", object, file)
+		for _, podSpec := range podSpecs {
+			volumes := hostpaths.HostPathVolumes(podSpec.Spec)
+			for _, mount := range hostpaths.Mounts(podSpec.Spec, volumes) {
+				if !path.IsAbs(mount.Volume.Path) {
+					// rejected by the kubelet anyway
+					continue
+				}
+				usages = append(usages, Usage{
+					Path:     path.Clean(mount.Volume.Path),
+					ReadOnly: mount.ReadOnly,
+					Workload: workload,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// Entry is an AllowedHostPaths entry, with the workloads motivating it.
+type Entry struct {
+	HostPath  hostpaths.HostPath
+	Workloads []string
+	// Conflicts lists the workloads mounting the exact same path with the
+	// opposite readOnly attribute. The policy cannot allow both, the
+	// read-only entry is kept and their mounts are rejected
+	Conflicts []string
+}
+
+// Learn computes a minimal list of AllowedHostPaths entries accepting all the
+// given usages. The paths are generalized to their ancestor of the given
+// depth, `/var/log/pods` becomes `/var/log` with depth 2, so that the
+// entries are never shallower than depth; 0 disables the generalization.
+// Deeper entries are added whenever needed to keep the readOnly attribute of
+// each usage, following the "most specific entry wins" rule of the policy.
+func Learn(usages []Usage, depth int) []Entry {
+	// generalize the paths, except when the same prefix is needed with
+	// both readOnly attributes
+	readOnlyByPrefix := make(map[string]map[bool]bool)
+	for _, usage := range usages {
+		prefix := truncate(usage.Path, depth)
+		if readOnlyByPrefix[prefix] == nil {
+			readOnlyByPrefix[prefix] = make(map[bool]bool)
+		}
+		readOnlyByPrefix[prefix][usage.ReadOnly] = true
+	}
+	entries := make(map[string]bool)
+	for _, usage := range usages {
+		prefix := truncate(usage.Path, depth)
+		if len(readOnlyByPrefix[prefix]) > 1 {
+			prefix = usage.Path
+		}
+		addEntry(entries, prefix, usage.ReadOnly)
+	}
+
+	// add the exact paths of the usages that are still rejected, until all
+	// of them are accepted or in conflict
+	for i := 0; i <= len(usages); i++ {
+		settings := newSettings(entries)
+		added := false
+		for _, usage := range usages {
+			if len(validate(usage, settings)) == 0 {
+				continue
+			}
+			if _, found := entries[usage.Path]; found && entries[usage.Path] != usage.ReadOnly {
+				// conflict, the path is already needed with the
+				// opposite readOnly attribute
+				continue
+			}
+			added = addEntry(entries, usage.Path, usage.ReadOnly) || added
+		}
+		if !added {
+			break
+		}
+	}
+
+	removeRedundantEntries(entries)
+
+	return attribute(usages, newSettings(entries))
+}
+
+// addEntry adds the given prefix to the entries, read-only entries win the
+// conflicts. It returns whether the entries changed.
+func addEntry(entries map[string]bool, prefix string, readOnly bool) bool {
+	current, found := entries[prefix]
+	if found && (current || current == readOnly) {
+		return false
+	}
+	entries[prefix] = readOnly
+	return true
+}
+
+// removeRedundantEntries removes the entries whose closest ancestor entry has
+// the same readOnly attribute, the paths they match resolve to the same
+// attribute without them.
+func removeRedundantEntries(entries map[string]bool) {
+	prefixes := sortedPrefixes(entries)
+	// visit the deepest entries first, their ancestors are still there
+	for i := len(prefixes) - 1; i >= 0; i-- {
+		prefix := prefixes[i]
+		ancestor, found := closestAncestor(entries, prefix)
+		if found && entries[ancestor] == entries[prefix] {
+			delete(entries, prefix)
+		}
+	}
+}
+
+func closestAncestor(entries map[string]bool, prefix string) (string, bool) {
+	closest := ""
+	found := false
+	for candidate := range entries {
+		if candidate == prefix || !hostpaths.HasPathPrefix(prefix, candidate) {
+			continue
+		}
+		if !found || len(candidate) > len(closest) {
+			closest, found = candidate, true
+		}
+	}
+	return closest, found
+}
+
+// attribute builds the entries of the given settings, with the usages they
+// accept or reject.
+func attribute(usages []Usage, settings hostpaths.Settings) []Entry {
+	entries := make([]Entry, 0, len(settings.AllowedHostPaths))
+	indexes := make(map[string]int)
+	for _, hostPath := range settings.AllowedHostPaths {
+		indexes[hostPath.PathPrefix] = len(entries)
+		entries = append(entries, Entry{
+			HostPath:  hostPath,
+			Workloads: make([]string, 0),
+			Conflicts: make([]string, 0),
+		})
+	}
+
+	for _, usage := range usages {
+		matching := ""
+		for _, hostPath := range settings.AllowedHostPaths {
+			if hostpaths.HasPathPrefix(usage.Path, hostPath.PathPrefix) && len(hostPath.PathPrefix) >= len(matching) {
+				matching = hostPath.PathPrefix
+			}
+		}
+		entry := &entries[indexes[matching]]
+		if len(validate(usage, settings)) == 0 {
+			entry.Workloads = appendUnique(entry.Workloads, usage.Workload)
+		} else {
+			entry.Conflicts = appendUnique(entry.Conflicts, usage.Workload)
+		}
+	}
+
+	for i := range entries {
+		sort.Strings(entries[i].Workloads)
+		sort.Strings(entries[i].Conflicts)
+	}
+	return entries
+}
+
+// validate evaluates the usage like the policy does.
+func validate(usage Usage, settings hostpaths.Settings) []hostpaths.Violation {
+	mount := hostpaths.Mount{
+		Volume:   hostpaths.Volume{Path: usage.Path},
+		ReadOnly: usage.ReadOnly,
+	}
+	return hostpaths.ValidateMounts(hostpaths.EmbeddedPodSpec{}, []hostpaths.Mount{mount}, settings)
+}
+
+func newSettings(entries map[string]bool) hostpaths.Settings {
+	settings := hostpaths.Settings{AllowedHostPaths: make([]hostpaths.HostPath, 0, len(entries))}
+	// ancestors are listed before their descendants
+	for _, prefix := range sortedPrefixes(entries) {
+		settings.AllowedHostPaths = append(settings.AllowedHostPaths, hostpaths.HostPath{
+			PathPrefix: prefix,
+			ReadOnly:   entries[prefix],
+		})
+	}
+	return settings
+}
+
+func sortedPrefixes(entries map[string]bool) []string {
+	prefixes := make([]string, 0, len(entries))
+	for prefix := range entries {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// truncate returns the ancestor of the given path with the given depth, or
+// the path itself when not deeper.
+func truncate(p string, depth int) string {
+	components := strings.Split(strings.Trim(p, "/"), "/")
+	if depth <= 0 || len(components) <= depth {
+		return p
+	}
+	return "/" + strings.Join(components[:depth], "/")
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// WriteYAML prints the settings of the given entries as YAML, with comments
+// listing the workloads motivating each entry. The other settings, like the
+// podSpecPaths, are copied from base, see learnedSettings.
+func WriteYAML(w io.Writer, entries []Entry, base hostpaths.Settings) error {
+	settings, err := learnedSettings(entries, base)
+	if err != nil {
+		return err
+	}
+	fields, err := otherSettings(settings)
+	if err != nil {
+		return err
+	}
+
+	b := strings.Builder{}
+	b.WriteString("allowedHostPaths:")
+	if len(entries) == 0 {
+		b.WriteString(" []")
+	}
+	b.WriteString("\n")
+	for _, entry := range entries {
+		for _, workload := range entry.Workloads {
+			fmt.Fprintf(&b, "  # %s\n", workload)
+		}
+		for _, workload := range entry.Conflicts {
+			fmt.Fprintf(&b, "  # CONFLICT, rejected because the mount is not read-only: %s\n", workload)
+		}
+		// JSON strings are valid YAML scalars
+		pathPrefix, err := json.Marshal(entry.HostPath.PathPrefix)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "  - pathPrefix: %s\n    readOnly: %t\n", pathPrefix, entry.HostPath.ReadOnly)
+	}
+	for _, field := range sortedKeys(fields) {
+		// JSON values are valid YAML flow values
+		fmt.Fprintf(&b, "%s: %s\n", field, fields[field])
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// learnedSettings returns the settings of the given entries, the other
+// settings being copied from base. The settings are validated like the policy
+// does, nothing is written when they would be rejected.
+func learnedSettings(entries []Entry, base hostpaths.Settings) (hostpaths.Settings, error) {
+	settings := base
+	settings.AllowedHostPaths = make([]hostpaths.HostPath, 0, len(entries))
+	for _, entry := range entries {
+		settings.AllowedHostPaths = append(settings.AllowedHostPaths, entry.HostPath)
+	}
+
+	payload, err := json.Marshal(settings)
+	if err != nil {
+		return settings, err
+	}
+	if _, err = hostpaths.ValidateSettingsPayload(payload); err != nil {
+		return settings, fmt.Errorf("invalid learned settings: %w", err)
+	}
+	return settings, nil
+}
+
+// otherSettings returns the JSON values of the settings other than the
+// allowedHostPaths, by name.
+func otherSettings(settings hostpaths.Settings) (map[string]json.RawMessage, error) {
+	payload, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "allowedHostPaths")
+	return fields, nil
+}
+
+func sortedKeys(fields map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteJSON prints the settings of the given entries as JSON to w, and the
+// workloads motivating each entry to motivations. The other settings, like
+// the podSpecPaths, are copied from base, see learnedSettings.
+func WriteJSON(w, motivations io.Writer, entries []Entry, base hostpaths.Settings) error {
+	settings, err := learnedSettings(entries, base)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		for _, workload := range entry.Workloads {
+			fmt.Fprintf(motivations, "'%s' readOnly %t: %s\n",
+				entry.HostPath.PathPrefix, entry.HostPath.ReadOnly, workload)
+		}
+		for _, workload := range entry.Conflicts {
+			fmt.Fprintf(motivations, "'%s' readOnly %t: CONFLICT, rejected because the mount is not read-only: %s\n",
+				entry.HostPath.PathPrefix, entry.HostPath.ReadOnly, workload)
+		}
+	}
+
+	payload, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(payload, '\n'))
+	return err
+}
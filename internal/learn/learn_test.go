@@ -0,0 +1,198 @@
+package learn
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestLearnGolden(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if err = os.Chdir("../../test_data/learn"); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	defer func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatalf("Unexpected error %+v", err)
+		}
+	}()
+
+	usages, err := Collect([]string{"cluster.json"}, hostpaths.Settings{})
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	actual := bytes.Buffer{}
+	if err = WriteYAML(&actual, Learn(usages, 2), hostpaths.Settings{}); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	golden := "settings.golden.yml"
+	if *update {
+		if err = os.WriteFile(golden, actual.Bytes(), 0o644); err != nil {
+			t.Fatalf("Unexpected error %+v", err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if !bytes.Equal(actual.Bytes(), expected) {
+		t.Errorf("Settings don't match %s, got:\n%s", golden, actual.String())
+	}
+}
+
+func TestLearn(t *testing.T) {
+	for _, tcase := range []struct {
+		name     string
+		usages   []Usage
+		depth    int
+		expected []hostpaths.HostPath
+	}{
+		{
+			name: "generalized to the depth",
+			usages: []Usage{
+				{Path: "/var/log/pods", ReadOnly: true, Workload: "a"},
+				{Path: "/var/log/containers", ReadOnly: true, Workload: "b"},
+				{Path: "/data", ReadOnly: false, Workload: "c"},
+			},
+			depth: 2,
+			expected: []hostpaths.HostPath{
+				{PathPrefix: "/data", ReadOnly: false},
+				{PathPrefix: "/var/log", ReadOnly: true},
+			},
+		},
+		{
+			name: "exact paths with depth 0",
+			usages: []Usage{
+				{Path: "/var/log/pods", ReadOnly: true, Workload: "a"},
+				{Path: "/var/log/pods/app", ReadOnly: true, Workload: "b"},
+				{Path: "/var/log/containers", ReadOnly: true, Workload: "c"},
+			},
+			depth: 0,
+			expected: []hostpaths.HostPath{
+				{PathPrefix: "/var/log/containers", ReadOnly: true},
+				{PathPrefix: "/var/log/pods", ReadOnly: true},
+			},
+		},
+		{
+			name: "prefix needed with both readOnly attributes",
+			usages: []Usage{
+				{Path: "/var/log/pods", ReadOnly: true, Workload: "a"},
+				{Path: "/var/log/app", ReadOnly: false, Workload: "b"},
+			},
+			depth: 2,
+			expected: []hostpaths.HostPath{
+				{PathPrefix: "/var/log/app", ReadOnly: false},
+				{PathPrefix: "/var/log/pods", ReadOnly: true},
+			},
+		},
+		{
+			name: "writable path under a read-only one",
+			usages: []Usage{
+				{Path: "/var", ReadOnly: true, Workload: "a"},
+				{Path: "/var/lib/app/data", ReadOnly: false, Workload: "b"},
+				{Path: "/var/lib/app/data/cache", ReadOnly: false, Workload: "c"},
+			},
+			depth: 2,
+			expected: []hostpaths.HostPath{
+				{PathPrefix: "/var", ReadOnly: true},
+				{PathPrefix: "/var/lib", ReadOnly: false},
+			},
+		},
+		{
+			name: "conflict",
+			usages: []Usage{
+				{Path: "/data", ReadOnly: false, Workload: "a"},
+				{Path: "/data", ReadOnly: true, Workload: "b"},
+			},
+			depth: 2,
+			expected: []hostpaths.HostPath{
+				{PathPrefix: "/data", ReadOnly: true},
+			},
+		},
+		{
+			name:     "no usage",
+			usages:   []Usage{},
+			depth:    2,
+			expected: []hostpaths.HostPath{},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			entries := Learn(tcase.usages, tcase.depth)
+			actual := make([]hostpaths.HostPath, 0, len(entries))
+			for _, entry := range entries {
+				actual = append(actual, entry.HostPath)
+			}
+			if !reflect.DeepEqual(actual, tcase.expected) {
+				t.Fatalf("Wanted %+v, but got %+v instead", tcase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWriteWithSettings(t *testing.T) {
+	base := hostpaths.Settings{
+		AllowedHostPaths: []hostpaths.HostPath{{PathPrefix: "/old", ReadOnly: true}},
+		PodSpecPaths: []hostpaths.PodSpecPath{
+			{Group: "example.com", Kind: "Agent", Paths: []string{"spec.template.spec"}},
+		},
+		Ratcheting: true,
+	}
+	entries := Learn([]Usage{{Path: "/var/log/pods", ReadOnly: true, Workload: "a"}}, 2)
+
+	actual := bytes.Buffer{}
+	if err := WriteYAML(&actual, entries, base); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	expected := `allowedHostPaths:
+  # a
+  - pathPrefix: "/var/log"
+    readOnly: true
+podSpecPaths: [{"group":"example.com","kind":"Agent","paths":["spec.template.spec"]}]
+ratcheting: true
+`
+	if actual.String() != expected {
+		t.Errorf("Wanted:\n%s\nbut got:\n%s", expected, actual.String())
+	}
+
+	actual.Reset()
+	if err := WriteJSON(&actual, &bytes.Buffer{}, entries, base); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	settings, err := hostpaths.ValidateSettingsPayload(actual.Bytes())
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	base.AllowedHostPaths = []hostpaths.HostPath{{PathPrefix: "/var/log", ReadOnly: true}}
+	if !reflect.DeepEqual(settings, base) {
+		t.Errorf("Wanted %+v, but got %+v instead", base, settings)
+	}
+}
+
+func TestWriteWithInvalidSettings(t *testing.T) {
+	base := hostpaths.Settings{PrivilegeGuard: "sometimes"}
+	entries := Learn([]Usage{{Path: "/var/log/pods", ReadOnly: true, Workload: "a"}}, 2)
+
+	actual := bytes.Buffer{}
+	err := WriteYAML(&actual, entries, base)
+	expected := "invalid learned settings: /privilegeGuard: expected one of: never, hostPaths, always"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Wanted error '%s', but got '%v' instead", expected, err)
+	}
+	if err = WriteJSON(&actual, &bytes.Buffer{}, entries, base); err == nil || err.Error() != expected {
+		t.Errorf("Wanted error '%s', but got '%v' instead", expected, err)
+	}
+	if actual.Len() > 0 {
+		t.Errorf("Unexpected output %s", actual.String())
+	}
+}
@@ -0,0 +1,263 @@
+// Package replay evaluates the requests recorded by Kubernetes audit logs
+// against two versions of the hostpaths-psp settings, to predict the impact
+// of a settings change.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+	"github.com/kubewarden/go-policy-template/internal/scan"
+)
+
+// maxEventSize is the size of the longest audit event line accepted.
+const maxEventSize = 16 * 1024 * 1024
+
+// Event is a CREATE or UPDATE request recorded by an audit log.
+type Event struct {
+	AuditID   string
+	Verb      string
+	Operation string
+	// APIGroup, APIVersion, SubResource, Namespace and Name come from the
+	// objectRef of the event, Name is empty for the create requests of
+	// objects with a generated name
+	APIGroup    string
+	APIVersion  string
+	SubResource string
+	Namespace   string
+	Name        string
+	// User is the authenticated user of the request, as logged
+	User json.RawMessage
+	// Object is the object of the request, the response object is
+	// preferred when logged because it holds the object after the
+	// mutations
+	Object json.RawMessage
+}
+
+type auditEvent struct {
+	Kind      string          `json:"kind"`
+	AuditID   string          `json:"auditID"`
+	Stage     string          `json:"stage"`
+	Verb      string          `json:"verb"`
+	User      json.RawMessage `json:"user"`
+	ObjectRef *struct {
+		Namespace   string `json:"namespace"`
+		Name        string `json:"name"`
+		APIGroup    string `json:"apiGroup"`
+		APIVersion  string `json:"apiVersion"`
+		Subresource string `json:"subresource"`
+	} `json:"objectRef"`
+	RequestObject  json.RawMessage `json:"requestObject"`
+	ResponseObject json.RawMessage `json:"responseObject"`
+}
+
+// Stats counts the audit events that cannot be replayed.
+type Stats struct {
+	// Skipped counts the events that are not validated by the policy,
+	// like DELETE requests or requests to subresources
+	Skipped int
+	// Incomplete counts the events without the object of the request,
+	// like the ones logged at the Metadata level or the patch requests
+	// logged at the Request level
+	Incomplete int
+}
+
+// ReadAuditLog reads the JSON lines of an audit log, and returns the CREATE
+// and UPDATE requests it holds. Every request is returned once, even when it
+// is logged at many stages.
+func ReadAuditLog(r io.Reader) ([]Event, Stats, error) {
+	events := make([]Event, 0)
+	stats := Stats{}
+	indexes := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		event := auditEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, stats, fmt.Errorf("cannot parse audit event at line %d: %w", line, err)
+		}
+		if event.Kind != "Event" || event.ObjectRef == nil {
+			continue
+		}
+		if event.Stage != "" && event.Stage != "ResponseComplete" {
+			// the request is logged again once completed
+			continue
+		}
+
+		operation := ""
+		switch event.Verb {
+		case "create":
+			operation = "CREATE"
+		case "update", "patch":
+			operation = "UPDATE"
+		}
+		subResource := event.ObjectRef.Subresource
+		if operation == "" || (subResource != "" && subResource != "ephemeralcontainers") {
+			// not validated by the policy, see shouldValidate
+			stats.Skipped++
+			continue
+		}
+
+		object := event.ResponseObject
+		if !isObject(object) && event.Verb != "patch" {
+			// the request object of patch requests is the patch
+			object = event.RequestObject
+		}
+		if !isObject(object) {
+			stats.Incomplete++
+			continue
+		}
+
+		replayed := Event{
+			AuditID:     event.AuditID,
+			Verb:        event.Verb,
+			Operation:   operation,
+			APIGroup:    event.ObjectRef.APIGroup,
+			APIVersion:  event.ObjectRef.APIVersion,
+			SubResource: subResource,
+			Namespace:   event.ObjectRef.Namespace,
+			Name:        event.ObjectRef.Name,
+			User:        event.User,
+			Object:      object,
+		}
+		if i, found := indexes[event.AuditID]; found && event.AuditID != "" {
+			events[i] = replayed
+			continue
+		}
+		indexes[event.AuditID] = len(events)
+		events = append(events, replayed)
+	}
+	return events, stats, scanner.Err()
+}
+
+// isObject returns whether the given JSON document is a Kubernetes object,
+// the response object of failed requests is a Status one.
+func isObject(raw json.RawMessage) bool {
+	if len(raw) == 0 || string(raw) == "null" {
+		return false
+	}
+	meta := struct {
+		Kind string `json:"kind"`
+	}{}
+	return json.Unmarshal(raw, &meta) == nil && meta.Kind != "" && meta.Kind != "Status"
+}
+
+// Outcome is the result of an event replayed against both settings.
+type Outcome struct {
+	Event    Event
+	Current  scan.Object
+	Proposed scan.Object
+}
+
+// Diff classifies the replayed events by the change of their outcome.
+type Diff struct {
+	NewlyRejected []Outcome
+	NewlyAccepted []Outcome
+	// Unchanged counts the events with the same outcome, by outcome
+	UnchangedRejected int
+	UnchangedAccepted int
+	// NotValidated counts the events of kinds not validated by the policy
+	NotValidated int
+	Stats        Stats
+}
+
+// Compare replays the given events against the current and the proposed
+// settings.
+func Compare(events []Event, current, proposed hostpaths.Settings) (Diff, error) {
+	diff := Diff{
+		NewlyRejected: make([]Outcome, 0),
+		NewlyAccepted: make([]Outcome, 0),
+	}
+	for _, event := range events {
+		currentObject, err := scan.EvaluateObject(event.Object, current)
+		if err != nil {
+			return diff, fmt.Errorf("audit event '%s': %w", event.AuditID, err)
+		}
+		if currentObject.SkipReason == scan.SkipKindNotValidated {
+			diff.NotValidated++
+			continue
+		}
+		proposedObject, err := scan.EvaluateObject(event.Object, proposed)
+		if err != nil {
+			return diff, fmt.Errorf("audit event '%s': %w", event.AuditID, err)
+		}
+
+		outcome := Outcome{
+			Event:    event,
+			Current:  currentObject,
+			Proposed: proposedObject,
+		}
+		currentRejected := len(currentObject.Violations) > 0
+		proposedRejected := len(proposedObject.Violations) > 0
+		switch {
+		case !currentRejected && proposedRejected:
+			diff.NewlyRejected = append(diff.NewlyRejected, outcome)
+		case currentRejected && !proposedRejected:
+			diff.NewlyAccepted = append(diff.NewlyAccepted, outcome)
+		case currentRejected:
+			diff.UnchangedRejected++
+		default:
+			diff.UnchangedAccepted++
+		}
+	}
+	return diff, nil
+}
+
+// WriteDiff prints the summary of the diff, followed by the events whose
+// outcome changes.
+func WriteDiff(w io.Writer, diff Diff) error {
+	var errs []error
+	printf := func(format string, args ...any) {
+		_, err := fmt.Fprintf(w, format, args...)
+		errs = append(errs, err)
+	}
+
+	printf("newly rejected: %d\n", len(diff.NewlyRejected))
+	printf("newly accepted: %d\n", len(diff.NewlyAccepted))
+	printf("unchanged: %d (%d rejected, %d accepted)\n",
+		diff.UnchangedRejected+diff.UnchangedAccepted, diff.UnchangedRejected, diff.UnchangedAccepted)
+	printf("not replayed: %d (%d kinds not validated, %d not validated requests, %d without object)\n",
+		diff.NotValidated+diff.Stats.Skipped+diff.Stats.Incomplete,
+		diff.NotValidated, diff.Stats.Skipped, diff.Stats.Incomplete)
+
+	for _, section := range []struct {
+		title    string
+		outcomes []Outcome
+		object   func(Outcome) scan.Object
+	}{
+		{
+			title:    "newly rejected",
+			outcomes: diff.NewlyRejected,
+			object:   func(o Outcome) scan.Object { return o.Proposed },
+		},
+		{
+			title:    "newly accepted",
+			outcomes: diff.NewlyAccepted,
+			object:   func(o Outcome) scan.Object { return o.Current },
+		},
+	} {
+		if len(section.outcomes) == 0 {
+			continue
+		}
+		printf("\n%s:\n", section.title)
+		for _, outcome := range section.outcomes {
+			object := section.object(outcome)
+			printf("  %s %s (audit ID %s)\n", outcome.Event.Operation, object, outcome.Event.AuditID)
+			for _, violation := range object.Violations {
+				printf("    %s\n", violation.Error())
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
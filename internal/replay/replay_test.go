@@ -0,0 +1,76 @@
+package replay
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func readSettings(t *testing.T, file string) hostpaths.Settings {
+	t.Helper()
+	payload, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	settings, err := hostpaths.ValidateSettingsPayload(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	return settings
+}
+
+func TestReplayAuditLog(t *testing.T) {
+	f, err := os.Open("../../test_data/replay/audit.log")
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	defer f.Close()
+
+	events, stats, err := ReadAuditLog(f)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if len(events) != 5 {
+		t.Errorf("Wanted 5 events, the request logged at many stages once, but got %d instead", len(events))
+	}
+
+	diff, err := Compare(events,
+		readSettings(t, "../../test_data/replay/settings-current.json"),
+		readSettings(t, "../../test_data/replay/settings-proposed.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	diff.Stats = stats
+
+	actual := bytes.Buffer{}
+	if err = WriteDiff(&actual, diff); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	golden := "../../test_data/replay/audit.golden.txt"
+	if *update {
+		if err = os.WriteFile(golden, actual.Bytes(), 0o644); err != nil {
+			t.Fatalf("Unexpected error %+v", err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if !bytes.Equal(actual.Bytes(), expected) {
+		t.Errorf("Diff doesn't match %s, got:\n%s", golden, actual.String())
+	}
+}
+
+func TestReadAuditLogWithInvalidLine(t *testing.T) {
+	_, _, err := ReadAuditLog(strings.NewReader("{\"kind\": \"Event\"}\nnot json\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "cannot parse audit event at line 2: ") {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package scan
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Metadata holds the annotations of the policy metadata.yml file reported by
+// the scan results.
+type Metadata struct {
+	Title    string
+	Version  string
+	URL      string
+	Category string
+	Severity string
+}
+
+// ReadMetadata reads the annotations of the policy from its metadata.yml
+// file. Only the flat `key: value` lines of the `annotations` block are
+// parsed, which is all the policy uses.
+func ReadMetadata(r io.Reader) (Metadata, error) {
+	metadata := Metadata{}
+	inAnnotations := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			// top-level key
+			inAnnotations = trimmed == "annotations:"
+			continue
+		}
+		if !inAnnotations {
+			continue
+		}
+
+		key, value, found := strings.Cut(trimmed, ":")
+		if !found {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch key {
+		case "io.kubewarden.policy.title":
+			metadata.Title = value
+		case "io.kubewarden.policy.version":
+			metadata.Version = value
+		case "io.kubewarden.policy.url":
+			metadata.URL = value
+		case "io.kubewarden.policy.category":
+			metadata.Category = value
+		case "io.kubewarden.policy.severity":
+			metadata.Severity = value
+		}
+	}
+	return metadata, scanner.Err()
+}
@@ -0,0 +1,184 @@
+package scan
+
+import (
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+)
+
+// now returns the timestamp of the PolicyReport results, replaced by the
+// tests.
+var now = time.Now
+
+// policyReportName is the name of the PolicyReport objects, they are
+// created inside of the namespace of the objects they report about.
+const policyReportName = "hostpaths-psp"
+
+type policyReportMetadata struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type policyReport struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Metadata   policyReportMetadata `json:"metadata"`
+	Summary    policyReportSummary  `json:"summary"`
+	Results    []policyReportResult `json:"results"`
+}
+
+type policyReportSummary struct {
+	Pass  int `json:"pass"`
+	Fail  int `json:"fail"`
+	Warn  int `json:"warn"`
+	Error int `json:"error"`
+	Skip  int `json:"skip"`
+}
+
+type policyReportResult struct {
+	Source    string                 `json:"source"`
+	Policy    string                 `json:"policy"`
+	Rule      string                 `json:"rule,omitempty"`
+	Category  string                 `json:"category,omitempty"`
+	Severity  string                 `json:"severity,omitempty"`
+	Timestamp policyReportTimestamp  `json:"timestamp"`
+	Result    string                 `json:"result"`
+	Scored    bool                   `json:"scored"`
+	Message   string                 `json:"message,omitempty"`
+	Resources []policyReportResource `json:"resources"`
+}
+
+type policyReportTimestamp struct {
+	Seconds int64 `json:"seconds"`
+	Nanos   int32 `json:"nanos"`
+}
+
+type policyReportResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	UID        string `json:"uid,omitempty"`
+}
+
+type policyReportList struct {
+	APIVersion string         `json:"apiVersion"`
+	Kind       string         `json:"kind"`
+	Items      []policyReport `json:"items"`
+}
+
+// writePolicyReports reports the objects as `wgpolicyk8s.io/v1alpha2`
+// PolicyReport objects, one per namespace, inside of a List. The objects
+// without namespace, like PersistentVolumes, are reported by a
+// ClusterPolicyReport. Each evaluated object gets a `pass` or `fail` result,
+// with the message produced by the policy; the objects skipped because of
+// empty settings get a `skip` result. The objects of kinds not validated by
+// the policy are not reported.
+func writePolicyReports(w io.Writer, objects []Object, metadata Metadata) error {
+	policy := metadata.Title
+	if policy == "" {
+		policy = "hostpaths-psp"
+	}
+	timestamp := now()
+
+	reports := make(map[string]*policyReport)
+	namespaces := make([]string, 0)
+	for _, object := range objects {
+		if object.SkipReason == SkipKindNotValidated {
+			continue
+		}
+
+		report, found := reports[object.Namespace]
+		if !found {
+			report = newPolicyReport(object.Namespace)
+			reports[object.Namespace] = report
+			namespaces = append(namespaces, object.Namespace)
+		}
+
+		result := policyReportResult{
+			Source:   "kubewarden",
+			Policy:   policy,
+			Rule:     policy,
+			Category: metadata.Category,
+			Severity: metadata.Severity,
+			Timestamp: policyReportTimestamp{
+				Seconds: timestamp.Unix(),
+				Nanos:   int32(timestamp.Nanosecond()),
+			},
+			Scored: true,
+			Resources: []policyReportResource{
+				{
+					APIVersion: object.APIVersion,
+					Kind:       object.Kind,
+					Namespace:  object.Namespace,
+					Name:       object.Name,
+					UID:        object.UID,
+				},
+			},
+		}
+		switch {
+		case object.Skipped():
+			result.Result = "skip"
+			result.Message = object.SkipReason
+			report.Summary.Skip++
+		case len(object.Violations) > 0:
+			result.Result = "fail"
+			result.Rule, result.Message = violationsRuleAndMessage(object.Violations)
+			report.Summary.Fail++
+		default:
+			result.Result = "pass"
+			report.Summary.Pass++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	sort.Strings(namespaces)
+	list := policyReportList{
+		APIVersion: "v1",
+		Kind:       "List",
+		Items:      make([]policyReport, 0, len(namespaces)),
+	}
+	for _, namespace := range namespaces {
+		list.Items = append(list.Items, *reports[namespace])
+	}
+	return writeIndentedJSON(w, list)
+}
+
+func newPolicyReport(namespace string) *policyReport {
+	kind := "PolicyReport"
+	if namespace == "" {
+		kind = "ClusterPolicyReport"
+	}
+	return &policyReport{
+		APIVersion: "wgpolicyk8s.io/v1alpha2",
+		Kind:       kind,
+		Metadata: policyReportMetadata{
+			Name:      policyReportName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "hostpaths-scan",
+			},
+		},
+		Results: make([]policyReportResult, 0),
+	}
+}
+
+// violationsRuleAndMessage returns the rules violated by an object, and the
+// message the policy rejects it with.
+func violationsRuleAndMessage(violations []hostpaths.Violation) (string, string) {
+	rules := make([]string, 0)
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		if !slices.Contains(rules, violation.Rule) {
+			rules = append(rules, violation.Rule)
+		}
+		messages = append(messages, violation.Error())
+	}
+	// same separator as errors.Join, used by validate
+	return strings.Join(rules, ","), strings.Join(messages, "\n")
+}
@@ -0,0 +1,397 @@
+package scan
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+)
+
+// Formats lists the output formats supported by Write.
+var Formats = []string{"text", "json", "sarif", "junit", "policyreport"}
+
+// rules describes the rules of the violations, in the order they are
+// reported by the SARIF output.
+var rules = []struct {
+	id          string
+	description string
+}{
+	{
+		id:          hostpaths.RuleNotAllowed,
+		description: "hostPath volumes must use a path inside of the AllowedHostPaths list",
+	},
+	{
+		id:          hostpaths.RuleKindNotAllowed,
+		description: "hostPath volumes must use a path whose AllowedHostPaths entry allows the kind of the object",
+	},
+	{
+		id:          hostpaths.RuleRuntimeClassNotAllowed,
+		description: "hostPath volumes must be used by pods whose RuntimeClass is allowed by the AllowedHostPaths entry of their path",
+	},
+	{
+		id:          hostpaths.RuleImageNotAllowed,
+		description: "hostPath volumes must be mounted by containers whose image is allowed by the AllowedHostPaths entry of their path",
+	},
+	{
+		id:          hostpaths.RuleReadOnly,
+		description: "hostPath volumes must be mounted with the readOnly attribute of their AllowedHostPaths entry",
+	},
+	{
+		id:          hostpaths.RuleTypeNotAllowed,
+		description: "hostPath PersistentVolumes must have a type inside of the PersistentVolumeHostPathTypes list",
+	},
+	{
+		id:          hostpaths.RuleIdentity,
+		description: "writable hostPath mounts must run with the identity required by their AllowedHostPaths entry",
+	},
+	{
+		id:          hostpaths.RuleConfinement,
+		description: "hostPath mounts must run with the SELinux type or AppArmor profile required by their AllowedHostPaths entry",
+	},
+	{
+		id:          hostpaths.RuleNodePinning,
+		description: "hostPath mounts must be done by pods pinned to the nodes labelled as required by their AllowedHostPaths entry",
+	},
+	{
+		id:          hostpaths.RulePrivileged,
+		description: "containers must not be privileged, they can mount any host path",
+	},
+	{
+		id:          hostpaths.RuleCapability,
+		description: "containers must not add dangerous capabilities, like SYS_ADMIN, allowing to mount any host path",
+	},
+}
+
+// Write reports the violations of the given objects to w, using the given
+// format.
+func Write(w io.Writer, format string, objects []Object, metadata Metadata) error {
+	switch format {
+	case "text":
+		return writeText(w, objects)
+	case "json":
+		return writeJSON(w, objects, metadata)
+	case "sarif":
+		return writeSARIF(w, objects, metadata)
+	case "junit":
+		return writeJUnit(w, objects, metadata)
+	case "policyreport":
+		return writePolicyReports(w, objects, metadata)
+	default:
+		return fmt.Errorf("unknown format '%s', expected one of: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// location returns the file of the given violation, followed by the path of
+// its pod spec when known.
+func location(object Object, violation hostpaths.Violation) string {
+	if path := object.ViolationPath(violation); path != "" {
+		return object.File + ":" + path
+	}
+	return object.File
+}
+
+func writeText(w io.Writer, objects []Object) error {
+	for _, object := range objects {
+		for _, violation := range object.Violations {
+			if _, err := fmt.Fprintf(w, "%s: %s: %s\n", location(object, violation), object, violation.Message); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type jsonResult struct {
+	File       string `json:"file"`
+	Path       string `json:"path,omitempty"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Rule       string `json:"rule"`
+	Severity   string `json:"severity,omitempty"`
+	Message    string `json:"message"`
+}
+
+func writeJSON(w io.Writer, objects []Object, metadata Metadata) error {
+	results := make([]jsonResult, 0)
+	for _, object := range objects {
+		for _, violation := range object.Violations {
+			results = append(results, jsonResult{
+				File:       object.File,
+				Path:       object.ViolationPath(violation),
+				APIVersion: object.APIVersion,
+				Kind:       object.Kind,
+				Namespace:  object.Namespace,
+				Name:       object.Name,
+				Rule:       violation.Rule,
+				Severity:   metadata.Severity,
+				Message:    violation.Message,
+			})
+		}
+	}
+	return writeIndentedJSON(w, results)
+}
+
+func writeIndentedJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           map[string]string  `json:"properties,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevel maps the severity of the policy to a SARIF level and to the
+// `security-severity` score used by GitHub code scanning.
+func sarifLevel(severity string) (string, string) {
+	switch severity {
+	case "critical":
+		return "error", "9.0"
+	case "high":
+		return "error", "7.0"
+	case "medium":
+		return "warning", "5.0"
+	case "low":
+		return "note", "3.0"
+	default:
+		return "warning", ""
+	}
+}
+
+func writeSARIF(w io.Writer, objects []Object, metadata Metadata) error {
+	level, securitySeverity := sarifLevel(metadata.Severity)
+
+	name := metadata.Title
+	if name == "" {
+		name = "hostpaths-psp"
+	}
+	driver := sarifDriver{
+		Name:           name,
+		Version:        metadata.Version,
+		InformationURI: metadata.URL,
+		Rules:          make([]sarifRule, 0, len(rules)),
+	}
+	for _, rule := range rules {
+		sarifRule := sarifRule{
+			ID:                   rule.id,
+			ShortDescription:     sarifMessage{Text: rule.description},
+			DefaultConfiguration: sarifConfiguration{Level: level},
+		}
+		if securitySeverity != "" {
+			sarifRule.Properties = map[string]string{"security-severity": securitySeverity}
+		}
+		driver.Rules = append(driver.Rules, sarifRule)
+	}
+
+	results := make([]sarifResult, 0)
+	for _, object := range objects {
+		for _, violation := range object.Violations {
+			location := sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(object.File)},
+				},
+			}
+			if path := object.ViolationPath(violation); path != "" {
+				location.LogicalLocations = []sarifLogicalLocation{
+					{FullyQualifiedName: path, Kind: "object"},
+				}
+			}
+			results = append(results, sarifResult{
+				RuleID:    violation.Rule,
+				Level:     level,
+				Message:   sarifMessage{Text: fmt.Sprintf("%s: %s", object, violation.Message)},
+				Locations: []sarifLocation{location},
+			})
+		}
+	}
+
+	return writeIndentedJSON(w, sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{
+			{
+				Tool:    sarifTool{Driver: driver},
+				Results: results,
+			},
+		},
+	})
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit reports a test suite per file, and a test case per object. The
+// objects with violations are failed test cases, the ones not validated by
+// the policy are skipped test cases.
+func writeJUnit(w io.Writer, objects []Object, metadata Metadata) error {
+	name := metadata.Title
+	if name == "" {
+		name = "hostpaths-psp"
+	}
+	report := junitTestSuites{Name: name}
+
+	suites := make(map[string]*junitTestSuite)
+	files := make([]string, 0)
+	for _, object := range objects {
+		suite, found := suites[object.File]
+		if !found {
+			suite = &junitTestSuite{Name: object.File}
+			suites[object.File] = suite
+			files = append(files, object.File)
+		}
+
+		testCase := junitTestCase{
+			ClassName: object.File,
+			Name:      object.String(),
+		}
+		switch {
+		case object.Skipped():
+			testCase.Skipped = &junitSkipped{Message: object.SkipReason}
+			suite.Skipped++
+		case len(object.Violations) > 0:
+			testCase.Failure = newJUnitFailure(object, metadata)
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	sort.Strings(files)
+	for _, file := range files {
+		suite := suites[file]
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, *suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func newJUnitFailure(object Object, metadata Metadata) *junitFailure {
+	messages := make([]string, 0, len(object.Violations))
+	ruleIDs := make([]string, 0)
+	lines := make([]string, 0, len(object.Violations))
+	for _, violation := range object.Violations {
+		messages = append(messages, violation.Error())
+		if !slices.Contains(ruleIDs, violation.Rule) {
+			ruleIDs = append(ruleIDs, violation.Rule)
+		}
+		line := fmt.Sprintf("%s: %s: %s", violation.Rule, location(object, violation), violation.Message)
+		if metadata.Severity != "" {
+			line = fmt.Sprintf("[%s] %s", metadata.Severity, line)
+		}
+		lines = append(lines, line)
+	}
+	return &junitFailure{
+		Message: strings.Join(messages, "; "),
+		Type:    strings.Join(ruleIDs, ","),
+		Text:    strings.Join(lines, "\n"),
+	}
+}
@@ -0,0 +1,136 @@
+package scan
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestWrite(t *testing.T) {
+	settings := readSettings(t)
+
+	// scan from the test_data directory, so that the golden files hold
+	// relative file names
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if err = os.Chdir("../../test_data/scan"); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	defer func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatalf("Unexpected error %+v", err)
+		}
+	}()
+
+	now = func() time.Time { return time.Unix(1700000000, 0) }
+	defer func() { now = time.Now }()
+
+	objects, err := Scan([]string{"manifests"}, settings)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	metadata := Metadata{
+		Title:    "hostpaths-psp",
+		Version:  "1.0.0",
+		URL:      "https://github.com/kubewarden/hostpaths-psp-policy",
+		Category: "PSP",
+		Severity: "medium",
+	}
+
+	for format, golden := range map[string]string{
+		"text":  "golden/report.txt",
+		"json":  "golden/report.json",
+		"sarif": "golden/report.sarif",
+		"junit": "golden/report.xml",
+		// PolicyReport objects of the manifests
+		"policyreport": "golden/policyreport.json",
+	} {
+		t.Run(format, func(t *testing.T) {
+			actual := bytes.Buffer{}
+			if err := Write(&actual, format, objects, metadata); err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+					t.Fatalf("Unexpected error %+v", err)
+				}
+				if err := os.WriteFile(golden, actual.Bytes(), 0o644); err != nil {
+					t.Fatalf("Unexpected error %+v", err)
+				}
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+			if !bytes.Equal(actual.Bytes(), expected) {
+				t.Errorf("Output doesn't match %s, got:\n%s", golden, actual.String())
+			}
+		})
+	}
+}
+
+func TestWriteWithUnknownFormat(t *testing.T) {
+	err := Write(&bytes.Buffer{}, "yaml", []Object{}, Metadata{})
+	expected := "unknown format 'yaml', expected one of: text, json, sarif, junit, policyreport"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Wanted error '%s', but got '%v' instead", expected, err)
+	}
+}
+
+func TestReadMetadata(t *testing.T) {
+	metadata, err := ReadMetadata(strings.NewReader(`rules:
+  - apiGroups:
+      - ""
+mutating: false
+annotations:
+  # kubewarden specific
+  io.kubewarden.policy.title: hostpaths-psp
+  io.kubewarden.policy.version: 1.1.3
+  io.kubewarden.policy.url: https://github.com/kubewarden/hostpaths-psp-policy
+  io.kubewarden.policy.category: PSP
+  io.kubewarden.policy.severity: "high"
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	expected := Metadata{
+		Title:    "hostpaths-psp",
+		Version:  "1.1.3",
+		URL:      "https://github.com/kubewarden/hostpaths-psp-policy",
+		Category: "PSP",
+		Severity: "high",
+	}
+	if metadata != expected {
+		t.Errorf("Wanted %+v, but got %+v instead", expected, metadata)
+	}
+}
+
+func TestWritePolicyReportsWithEmptySettings(t *testing.T) {
+	objects, err := Scan([]string{"../../test_data/scan/manifests"}, hostpaths.Settings{})
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	actual := bytes.Buffer{}
+	if err = Write(&actual, "policyreport", objects, Metadata{}); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	// all the evaluated objects are skipped, the ConfigMap isn't reported
+	if strings.Count(actual.String(), `"result": "skip"`) != 5 ||
+		strings.Contains(actual.String(), "ConfigMap") {
+		t.Errorf("Unexpected PolicyReports %s", actual.String())
+	}
+}
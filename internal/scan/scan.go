@@ -0,0 +1,312 @@
+// Package scan evaluates the hostpaths-psp policy against Kubernetes
+// manifests stored on disk, without a Wasm runtime nor a cluster.
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// Object is a Kubernetes object found inside of the scanned manifests, with
+// the violations of its hostPath volumes.
+type Object struct {
+	File string
+	// Path is the gjson path of the object inside of its JSON document,
+	// like `items[3]` for the items of List objects. It is empty for the
+	// top-level objects
+	Path       string
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	UID        string
+	// SkipReason explains why the object has not been evaluated, it is
+	// empty for the evaluated objects
+	SkipReason string
+	Violations []hostpaths.Violation
+}
+
+// Reasons of the objects that have not been evaluated.
+const (
+	SkipKindNotValidated = "kind not validated by the policy"
+	SkipEmptySettings    = "empty allowedHostPaths, the policy accepts all the objects"
+)
+
+// Warnings receives the warnings of the walks, like the JSON documents
+// skipped because they are not Kubernetes objects.
+var Warnings io.Writer = os.Stderr
+
+// Skipped returns whether the object has not been evaluated.
+func (o Object) Skipped() bool {
+	return o.SkipReason != ""
+}
+
+// String identifies the object inside of the scan results.
+func (o Object) String() string {
+	if o.Namespace == "" {
+		return fmt.Sprintf("%s %s", o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+// ViolationPath returns the gjson path of the pod spec of the given violation
+// inside of the JSON document of the object.
+func (o Object) ViolationPath(violation hostpaths.Violation) string {
+	switch {
+	case o.Path == "":
+		return violation.PodSpec
+	case violation.PodSpec == "":
+		return o.Path
+	default:
+		return o.Path + "." + violation.PodSpec
+	}
+}
+
+type objectMeta struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+		UID       string `json:"uid"`
+	} `json:"metadata"`
+	Items []json.RawMessage `json:"items"`
+}
+
+// Scan evaluates all the objects found inside of the given JSON files, and
+// inside of the JSON files of the given directories, see Walk.
+func Scan(paths []string, settings hostpaths.Settings) ([]Object, error) {
+	objects := make([]Object, 0)
+	err := Walk(paths, func(file, path string, raw json.RawMessage) error {
+		object, err := evaluateFileObject(file, path, raw, settings)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ScanReader evaluates all the objects of the JSON documents read from r,
+// file is the name reported by the results.
+func ScanReader(file string, r io.Reader, settings hostpaths.Settings) ([]Object, error) {
+	objects := make([]Object, 0)
+	err := WalkReader(file, r, func(file, path string, raw json.RawMessage) error {
+		object, err := evaluateFileObject(file, path, raw, settings)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func evaluateFileObject(file, path string, raw json.RawMessage, settings hostpaths.Settings) (Object, error) {
+	object, err := EvaluateObject(raw, settings)
+	if err != nil {
+		return Object{}, fmt.Errorf("%s: %w", file, err)
+	}
+	object.File = file
+	object.Path = path
+	return object, nil
+}
+
+// WalkFunc is called by Walk for each object found, with the file holding
+// it and its gjson path inside of its JSON document.
+type WalkFunc func(file, path string, raw json.RawMessage) error
+
+// Walk calls fn for all the objects found inside of the given JSON files, and
+// inside of the JSON files of the given directories, walked recursively.
+// Files can hold any number of JSON documents, either single objects or
+// List ones like the output of `kubectl get -o json`. The documents that are
+// not Kubernetes objects, without apiVersion or kind, are skipped with a
+// warning.
+func Walk(paths []string, fn WalkFunc) error {
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || (path != root && filepath.Ext(path) != ".json") {
+				// files given explicitly are always scanned
+				return nil
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			return WalkReader(path, f, fn)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkReader calls fn for all the objects of the JSON documents read from r,
+// file is the name passed to fn.
+func WalkReader(file string, r io.Reader, fn WalkFunc) error {
+	decoder := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot parse %s: %w", file, err)
+		}
+
+		if err = walkObject(file, "", raw, fn); err != nil {
+			return err
+		}
+	}
+}
+
+func walkObject(file, path string, raw json.RawMessage, fn WalkFunc) error {
+	meta := objectMeta{}
+	if !isJSONObject(raw) {
+		warnNotObject(file, path)
+		return nil
+	}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return fmt.Errorf("cannot parse %s: %w", file, err)
+	}
+	if meta.APIVersion == "" || meta.Kind == "" {
+		warnNotObject(file, path)
+		return nil
+	}
+
+	if meta.Kind == "List" || (strings.HasSuffix(meta.Kind, "List") && meta.Items != nil) {
+		for i, item := range meta.Items {
+			itemPath := fmt.Sprintf("items[%d]", i)
+			if path != "" {
+				itemPath = path + "." + itemPath
+			}
+			if err := walkObject(file, itemPath, item, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fn(file, path, raw)
+}
+
+// isJSONObject returns whether the JSON document is an object, and not an
+// array or a scalar.
+func isJSONObject(raw json.RawMessage) bool {
+	trimmed := bytes.TrimLeft(raw, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+func warnNotObject(file, path string) {
+	if path != "" {
+		file = file + ":" + path
+	}
+	fmt.Fprintf(Warnings, "warning: %s: skipping a JSON document that is not a Kubernetes object\n", file)
+}
+
+// EvaluateObject evaluates a single object like the policy does. The File and
+// Path fields of the returned Object are not set.
+func EvaluateObject(raw json.RawMessage, settings hostpaths.Settings) (Object, error) {
+	object, gvk, err := ParseObject(raw)
+	if err != nil {
+		return Object{}, err
+	}
+
+	violations, skipReason, err := evaluateObject(raw, object.Name, gvk, settings)
+	if err != nil {
+		return Object{}, fmt.Errorf("%s: %w", object, err)
+	}
+	object.SkipReason = skipReason
+	object.Violations = append(object.Violations, violations...)
+	return object, nil
+}
+
+// ParseObject returns the Object describing the given object, without
+// evaluating it, along with its kind.
+func ParseObject(raw json.RawMessage) (Object, kubewarden_protocol.GroupVersionKind, error) {
+	meta := objectMeta{}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return Object{}, kubewarden_protocol.GroupVersionKind{}, err
+	}
+
+	object := Object{
+		APIVersion: meta.APIVersion,
+		Kind:       meta.Kind,
+		Namespace:  meta.Metadata.Namespace,
+		Name:       meta.Metadata.Name,
+		UID:        meta.Metadata.UID,
+		Violations: make([]hostpaths.Violation, 0),
+	}
+	return object, groupVersionKind(meta.APIVersion, meta.Kind), nil
+}
+
+// evaluateObject evaluates the given object like the policy does, and returns
+// the reason why it has not been evaluated, if any.
+func evaluateObject(raw json.RawMessage, name string, gvk kubewarden_protocol.GroupVersionKind, settings hostpaths.Settings) ([]hostpaths.Violation, string, error) {
+	if gvk.Group == "" && gvk.Kind == "PersistentVolume" {
+		persistentVolume := corev1.PersistentVolume{}
+		if err := json.Unmarshal(raw, &persistentVolume); err != nil {
+			return nil, "", err
+		}
+		if len(settings.AllowedHostPaths) == 0 && len(settings.PersistentVolumeHostPathTypes) == 0 {
+			return nil, SkipEmptySettings, nil
+		}
+		return hostpaths.EvaluatePersistentVolume(name, persistentVolume, settings), "", nil
+	}
+
+	if !hostpaths.HasPodSpecs(gvk, settings) {
+		return nil, SkipKindNotValidated, nil
+	}
+	podSpecs, err := hostpaths.ExtractPodSpecs(gvk, raw, settings)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(settings.AllowedHostPaths) == 0 && !settings.PrivilegeGuardEnabled() {
+		return nil, SkipEmptySettings, nil
+	}
+
+	violations := make([]hostpaths.Violation, 0)
+	for _, podSpec := range podSpecs {
+		violations = append(violations, hostpaths.Evaluate(podSpec, settings)...)
+	}
+	return violations, "", nil
+}
+
+func groupVersionKind(apiVersion, kind string) kubewarden_protocol.GroupVersionKind {
+	group, version, found := strings.Cut(apiVersion, "/")
+	if !found {
+		// core group, like `v1`
+		group, version = "", apiVersion
+	}
+	return kubewarden_protocol.GroupVersionKind{
+		Group:   group,
+		Version: version,
+		Kind:    kind,
+	}
+}
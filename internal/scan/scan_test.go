@@ -0,0 +1,124 @@
+package scan
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+)
+
+func readSettings(t *testing.T) hostpaths.Settings {
+	t.Helper()
+	payload, err := os.ReadFile("../../test_data/scan/settings.json")
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	settings, err := hostpaths.ValidateSettingsPayload(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	return settings
+}
+
+func TestScan(t *testing.T) {
+	objects, err := Scan([]string{"../../test_data/scan/manifests"}, readSettings(t))
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	type result struct {
+		object     string
+		skipped    bool
+		violations []string
+	}
+	expected := []result{
+		{
+			object: "Deployment default/web",
+		},
+		{
+			object:     "CronJob default/cleanup",
+			violations: []string{"spec.jobTemplate.spec.template.spec: hostPath '/' mounted as 'root' is not in the AllowedHostPaths list"},
+		},
+		{
+			object:     "DaemonSet monitoring/agent",
+			violations: []string{"items[0].spec.template.spec: hostPath '/var/log' mounted as 'logs' should be readOnly 'true'"},
+		},
+		{
+			object:  "ConfigMap monitoring/agent",
+			skipped: true,
+		},
+		{
+			object: "Pod default/debug",
+		},
+		{
+			object:     "PersistentVolume etc",
+			violations: []string{"hostPath '/etc' of PersistentVolume 'etc' is not in the AllowedHostPaths list"},
+		},
+	}
+
+	actual := make([]result, 0, len(objects))
+	for _, object := range objects {
+		r := result{
+			object:  object.String(),
+			skipped: object.Skipped(),
+		}
+		for _, violation := range object.Violations {
+			message := violation.Message
+			if path := object.ViolationPath(violation); path != "" {
+				message = path + ": " + message
+			}
+			r.violations = append(r.violations, message)
+		}
+		actual = append(actual, r)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Wanted %+v, but got %+v instead", expected, actual)
+	}
+}
+
+func TestScanReaderWithEmptySettings(t *testing.T) {
+	f, err := os.Open("../../test_data/scan/manifests/cronjob.json")
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	defer f.Close()
+
+	objects, err := ScanReader("cronjob.json", f, hostpaths.Settings{})
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if len(objects) != 1 || len(objects[0].Violations) != 0 || objects[0].SkipReason != SkipEmptySettings {
+		t.Errorf("Unexpected scan results %+v", objects)
+	}
+}
+
+func TestScanReaderWithInvalidJSON(t *testing.T) {
+	_, err := ScanReader("broken.json", strings.NewReader(`{"kind": `), readSettings(t))
+	if err == nil || !strings.HasPrefix(err.Error(), "cannot parse broken.json: ") {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestScanSkipsDocumentsThatAreNotObjects(t *testing.T) {
+	var warnings strings.Builder
+	Warnings = &warnings
+	defer func() { Warnings = os.Stderr }()
+
+	objects, err := Scan([]string{"../../test_data/scan/not-objects.json"}, readSettings(t))
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if len(objects) != 1 || objects[0].String() != "Pod default/debug" || objects[0].Path != "items[1]" {
+		t.Errorf("Unexpected scan results %+v", objects)
+	}
+
+	expected := "warning: ../../test_data/scan/not-objects.json: skipping a JSON document that is not a Kubernetes object\n" +
+		"warning: ../../test_data/scan/not-objects.json: skipping a JSON document that is not a Kubernetes object\n" +
+		"warning: ../../test_data/scan/not-objects.json:items[0]: skipping a JSON document that is not a Kubernetes object\n"
+	if warnings.String() != expected {
+		t.Errorf("Wanted '%s', but got '%s' instead", expected, warnings.String())
+	}
+}
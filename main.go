@@ -3,6 +3,7 @@ package main
 import (
 	onelog "github.com/francoispqt/onelog"
 	kubewarden "github.com/kubewarden/policy-sdk-go"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities"
 	wapc "github.com/wapc/wapc-guest-tinygo"
 )
 
@@ -12,6 +13,9 @@ var (
 		&logWriter,
 		onelog.ALL, // shortcut for onelog.DEBUG|onelog.INFO|onelog.WARN|onelog.ERROR|onelog.FATAL
 	)
+	// host gives access to the Kubernetes resources, used when the
+	// PersistentVolumeClaims of the pods are checked
+	host = capabilities.NewHost()
 )
 
 func main() {
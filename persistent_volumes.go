@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	onelog "github.com/francoispqt/onelog"
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	kubewarden "github.com/kubewarden/policy-sdk-go"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// validatePersistentVolume validates PersistentVolume objects, their
+// `hostPath` or `local` path must be inside of the AllowedHostPaths list.
+func validatePersistentVolume(validationRequest kubewarden_protocol.ValidationRequest, settings Settings) ([]byte, error) {
+	persistentVolume := corev1.PersistentVolume{}
+	if err := json.Unmarshal(validationRequest.Request.Object, &persistentVolume); err != nil {
+		return kubewarden.RejectRequest(
+			kubewarden.Message(err.Error()),
+			kubewarden.Code(400))
+	}
+
+	if hostpaths.PersistentVolumePath(persistentVolume) == "" {
+		// not backed by a path of the node
+		return kubewarden.AcceptRequest()
+	}
+
+	logger.DebugWithFields("validating persistent volume object", func(e onelog.Entry) {
+		e.String("name", validationRequest.Request.Name)
+		e.Bool("dryRun", validationRequest.Request.DryRun)
+	})
+
+	errs := make([]error, 0)
+	for _, violation := range hostpaths.EvaluatePersistentVolume(validationRequest.Request.Name, persistentVolume, settings) {
+		errs = append(errs, violation)
+	}
+	err := errors.Join(errs...)
+	if err == nil {
+		return kubewarden.AcceptRequest()
+	}
+
+	logger.DebugWithFields("rejecting persistent volume object", func(e onelog.Entry) {
+		e.String("name", validationRequest.Request.Name)
+		e.Bool("dryRun", validationRequest.Request.DryRun)
+	})
+	return kubewarden.RejectRequest(
+		kubewarden.Message(err.Error()),
+		kubewarden.NoCode)
+}
+
+// getPersistentHostPathVolumes resolves the PersistentVolumeClaim volumes of
+// the given pod spec to their bound PersistentVolumes, and returns the ones
+// giving access to a path of the node through a `hostPath` or `local` volume
+// source.
+// Claims that are not bound yet are skipped, their PersistentVolume is not
+// known at admission time.
+func getPersistentHostPathVolumes(podSpec corev1.PodSpec, namespace string) ([]hostpaths.Volume, error) {
+	volumes := make([]hostpaths.Volume, 0)
+	for _, volume := range podSpec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		if volume.PersistentVolumeClaim.ClaimName == nil || *volume.PersistentVolumeClaim.ClaimName == "" {
+			// invalid volume, rejected by the API server anyway
+			continue
+		}
+		claimName := *volume.PersistentVolumeClaim.ClaimName
+
+		claim, err := getPersistentVolumeClaim(claimName, namespace)
+		if errors.Is(err, errNotFound) {
+			// pods can use claims created later, like unbound ones
+			logger.WarnWithFields("skipping missing PersistentVolumeClaim", func(e onelog.Entry) {
+				e.String("name", claimName)
+				e.String("namespace", namespace)
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if claim.Spec == nil || claim.Spec.VolumeName == "" {
+			logger.DebugWithFields("skipping unbound PersistentVolumeClaim", func(e onelog.Entry) {
+				e.String("name", claimName)
+				e.String("namespace", namespace)
+			})
+			continue
+		}
+
+		persistentVolume, err := getPersistentVolume(claim.Spec.VolumeName)
+		if err != nil {
+			return nil, err
+		}
+		path := hostpaths.PersistentVolumePath(persistentVolume)
+		if path == "" {
+			// not backed by a path of the node
+			continue
+		}
+
+		volumes = append(volumes, hostpaths.Volume{
+			Name:             *volume.Name,
+			Path:             path,
+			PersistentVolume: claim.Spec.VolumeName,
+			Claim:            claimName,
+			ReadOnly:         volume.PersistentVolumeClaim.ReadOnly,
+		})
+	}
+	return volumes, nil
+}
+
+// errNotFound is returned when the requested resource doesn't exist.
+var errNotFound = errors.New("not found")
+
+// isNotFound returns whether the error of a host callback reports a missing
+// resource. The host callbacks don't return typed errors: the status of the
+// API server response, embedded in the message like
+// `ErrorResponse { ..., reason: "NotFound", code: 404 }`, is matched instead.
+// Other errors mentioning a missing object, like the RBAC ones, don't match.
+func isNotFound(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, `reason: "NotFound"`) && strings.Contains(message, "code: 404")
+}
+
+func getPersistentVolumeClaim(name, namespace string) (corev1.PersistentVolumeClaim, error) {
+	claim := corev1.PersistentVolumeClaim{}
+	payload, err := kubernetes.GetResource(&host, kubernetes.GetResourceRequest{
+		APIVersion: "v1",
+		Kind:       "PersistentVolumeClaim",
+		Name:       name,
+		Namespace:  &namespace,
+	})
+	if err != nil && isNotFound(err) {
+		return claim, fmt.Errorf("PersistentVolumeClaim '%s/%s': %w", namespace, name, errNotFound)
+	}
+	if err != nil {
+		return claim, fmt.Errorf("cannot get PersistentVolumeClaim '%s/%s': %w", namespace, name, err)
+	}
+	if err = json.Unmarshal(payload, &claim); err != nil {
+		return claim, fmt.Errorf("cannot parse PersistentVolumeClaim '%s/%s': %w", namespace, name, err)
+	}
+	return claim, nil
+}
+
+func getPersistentVolume(name string) (corev1.PersistentVolume, error) {
+	persistentVolume := corev1.PersistentVolume{}
+	payload, err := kubernetes.GetResource(&host, kubernetes.GetResourceRequest{
+		APIVersion: "v1",
+		Kind:       "PersistentVolume",
+		Name:       name,
+	})
+	if err != nil {
+		return persistentVolume, fmt.Errorf("cannot get PersistentVolume '%s': %w", name, err)
+	}
+	if err = json.Unmarshal(payload, &persistentVolume); err != nil {
+		return persistentVolume, fmt.Errorf("cannot parse PersistentVolume '%s': %w", name, err)
+	}
+	return persistentVolume, nil
+}
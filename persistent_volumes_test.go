@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+)
+
+// fakeKubernetes is a local stand-in for the Kubernetes host callbacks,
+// serving the resources it holds, indexed by "kind/namespace/name".
+type fakeKubernetes struct {
+	resources map[string]string
+	// failures holds the errors of the lookups of some resources
+	failures map[string]string
+}
+
+func (f *fakeKubernetes) HostCall(binding, namespace, operation string, payload []byte) ([]byte, error) {
+	if binding != "kubewarden" || namespace != "kubernetes" || operation != "get_resource" {
+		return nil, fmt.Errorf("unexpected host call %s/%s/%s", binding, namespace, operation)
+	}
+
+	req := kubernetes.GetResourceRequest{}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	resourceNamespace := ""
+	if req.Namespace != nil {
+		resourceNamespace = *req.Namespace
+	}
+
+	key := req.Kind + "/" + resourceNamespace + "/" + req.Name
+	if failure, found := f.failures[key]; found {
+		return nil, errors.New(failure)
+	}
+	resource, found := f.resources[key]
+	if !found {
+		return nil, notFoundError(req.Kind, req.Name)
+	}
+	return []byte(resource), nil
+}
+
+// notFoundError returns the error of the host callbacks for a missing
+// resource, holding the status returned by the API server.
+func notFoundError(kind, name string) error {
+	resource := strings.ToLower(kind) + "s"
+	message := fmt.Sprintf("%s \"%s\" not found", resource, name)
+	return fmt.Errorf("ApiError: %s: NotFound (ErrorResponse { status: \"Failure\", message: %q, reason: \"NotFound\", code: 404 })",
+		message, message)
+}
+
+var persistentVolumeResources = map[string]string{
+	"PersistentVolumeClaim/default/data-claim": `{
+		"apiVersion": "v1",
+		"kind": "PersistentVolumeClaim",
+		"metadata": {"name": "data-claim", "namespace": "default"},
+		"spec": {"volumeName": "pv-data"}
+	}`,
+	"PersistentVolumeClaim/default/logs-claim": `{
+		"apiVersion": "v1",
+		"kind": "PersistentVolumeClaim",
+		"metadata": {"name": "logs-claim", "namespace": "default"},
+		"spec": {"volumeName": "pv-logs"}
+	}`,
+	"PersistentVolumeClaim/default/pending-claim": `{
+		"apiVersion": "v1",
+		"kind": "PersistentVolumeClaim",
+		"metadata": {"name": "pending-claim", "namespace": "default"},
+		"spec": {}
+	}`,
+	"PersistentVolume//pv-data": `{
+		"apiVersion": "v1",
+		"kind": "PersistentVolume",
+		"metadata": {"name": "pv-data"},
+		"spec": {"hostPath": {"path": "/data"}}
+	}`,
+	"PersistentVolume//pv-logs": `{
+		"apiVersion": "v1",
+		"kind": "PersistentVolume",
+		"metadata": {"name": "pv-logs"},
+		"spec": {"local": {"path": "/var/log"}}
+	}`,
+}
+
+func TestPersistentVolumeClaims(t *testing.T) {
+	for _, tcase := range []struct {
+		name      string
+		testData  string
+		resources map[string]string
+		failures  map[string]string
+		settings  Settings
+		error     string
+	}{
+		{
+			name:      "claims are not checked",
+			resources: map[string]string{},
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/var",
+						ReadOnly:   true,
+					},
+				},
+			},
+		},
+		{
+			name:      "persistent volumes in the allow list",
+			resources: persistentVolumeResources,
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/data",
+						ReadOnly:   false,
+					},
+					{
+						PathPrefix: "/var",
+						ReadOnly:   true,
+					},
+				},
+				CheckPersistentVolumeClaims: true,
+			},
+		},
+		{
+			name:      "persistent volumes not in the allow list",
+			resources: persistentVolumeResources,
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/var",
+						ReadOnly:   false,
+					},
+					{
+						PathPrefix: "/var/log",
+						ReadOnly:   false,
+					},
+				},
+				CheckPersistentVolumeClaims: true,
+			},
+			error: "hostPath '/var' mounted as 'test-var' should be readOnly 'false'\n" +
+				"hostPath '/data' of PersistentVolume 'pv-data' bound to claim 'data-claim' mounted as 'data' is not in the AllowedHostPaths list\n" +
+				"hostPath '/var/log' of PersistentVolume 'pv-logs' bound to claim 'logs-claim' mounted as 'logs' should be readOnly 'false'\n" +
+				"hostPath '/var/log' of PersistentVolume 'pv-logs' bound to claim 'logs-claim' mounted as 'logs' should be readOnly 'false'",
+		},
+		{
+			name:      "missing claims",
+			resources: map[string]string{},
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/var",
+						ReadOnly:   true,
+					},
+				},
+				CheckPersistentVolumeClaims: true,
+			},
+		},
+		{
+			name:      "claim lookup failure",
+			resources: persistentVolumeResources,
+			failures: map[string]string{
+				"PersistentVolumeClaim/default/data-claim": "forbidden",
+			},
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/var",
+						ReadOnly:   true,
+					},
+				},
+				CheckPersistentVolumeClaims: true,
+			},
+			error: "cannot get PersistentVolumeClaim 'default/data-claim': forbidden",
+		},
+		{
+			name:      "claim lookup failure mentioning a missing object",
+			resources: persistentVolumeResources,
+			failures: map[string]string{
+				"PersistentVolumeClaim/default/data-claim": `persistentvolumeclaims "data-claim" is forbidden: ` +
+					`RBAC: clusterrole.rbac.authorization.k8s.io "view" not found`,
+			},
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/var",
+						ReadOnly:   true,
+					},
+				},
+				CheckPersistentVolumeClaims: true,
+			},
+			error: `cannot get PersistentVolumeClaim 'default/data-claim': persistentvolumeclaims "data-claim" is forbidden: ` +
+				`RBAC: clusterrole.rbac.authorization.k8s.io "view" not found`,
+		},
+		{
+			name:      "claim without name",
+			testData:  "test_data/request-pod-pvc-no-claimname.json",
+			resources: map[string]string{},
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/var",
+						ReadOnly:   true,
+					},
+				},
+				CheckPersistentVolumeClaims: true,
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			testData := tcase.testData
+			if testData == "" {
+				testData = "test_data/request-pod-pvc.json"
+			}
+			validateFixture(t, testData, tcase.settings,
+				&fakeKubernetes{resources: tcase.resources, failures: tcase.failures}, tcase.error)
+		})
+	}
+}
+
+func TestPersistentVolumes(t *testing.T) {
+	settings := Settings{
+		AllowedHostPaths: []HostPath{
+			{
+				PathPrefix: "/data",
+				ReadOnly:   true,
+			},
+			{
+				PathPrefix: "/mnt/disks",
+				ReadOnly:   false,
+			},
+		},
+	}
+
+	for _, tcase := range []struct {
+		name     string
+		testData string
+		settings Settings
+		error    string
+	}{
+		{
+			name:     "hostPath persistent volume in the allow list",
+			testData: "test_data/request-pv-hostpath.json",
+			settings: settings,
+		},
+		{
+			name:     "local persistent volume in the allow list",
+			testData: "test_data/request-pv-local.json",
+			settings: settings,
+		},
+		{
+			name:     "persistent volume not backed by the node",
+			testData: "test_data/request-pv-nfs.json",
+			settings: settings,
+		},
+		{
+			name:     "hostPath persistent volume not in the allow list",
+			testData: "test_data/request-pv-hostpath.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/data/vol",
+						ReadOnly:   true,
+					},
+				},
+			},
+			error: "hostPath '/data/volumes/pv-data' of PersistentVolume 'pv-data' is not in the AllowedHostPaths list",
+		},
+		{
+			name:     "local persistent volume not in the allow list",
+			testData: "test_data/request-pv-local.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/data",
+						ReadOnly:   true,
+					},
+				},
+			},
+			error: "hostPath '/mnt/disks/ssd1' of PersistentVolume 'pv-local' is not in the AllowedHostPaths list",
+		},
+		{
+			name:     "hostPath persistent volume allowed only for some workloads",
+			testData: "test_data/request-pv-hostpath.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix:   "/data",
+						ReadOnly:     true,
+						AllowedKinds: []string{"DaemonSet"},
+					},
+					{
+						PathPrefix:    "/data/volumes",
+						ReadOnly:      false,
+						AllowedImages: []string{"registry.example.com/*"},
+					},
+				},
+			},
+			error: "hostPath '/data/volumes/pv-data' of PersistentVolume 'pv-data' is only allowed for some workloads by the AllowedHostPaths list",
+		},
+		{
+			name:     "hostPath persistent volume with an allowed type",
+			testData: "test_data/request-pv-hostpath.json",
+			settings: Settings{
+				AllowedHostPaths:              settings.AllowedHostPaths,
+				PersistentVolumeHostPathTypes: []string{"Directory", "DirectoryOrCreate"},
+			},
+		},
+		{
+			name:     "hostPath persistent volume with a type not allowed",
+			testData: "test_data/request-pv-hostpath.json",
+			settings: Settings{
+				PersistentVolumeHostPathTypes: []string{"Directory"},
+			},
+			error: "hostPath '/data/volumes/pv-data' of PersistentVolume 'pv-data' has type 'DirectoryOrCreate' instead of 'Directory'",
+		},
+		{
+			name:     "hostPath persistent volume with a type and a path not allowed",
+			testData: "test_data/request-pv-hostpath.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/data/vol",
+						ReadOnly:   true,
+					},
+				},
+				PersistentVolumeHostPathTypes: []string{"Directory"},
+			},
+			error: "hostPath '/data/volumes/pv-data' of PersistentVolume 'pv-data' has type 'DirectoryOrCreate' instead of 'Directory'\n" +
+				"hostPath '/data/volumes/pv-data' of PersistentVolume 'pv-data' is not in the AllowedHostPaths list",
+		},
+		{
+			name:     "local persistent volume with hostPath types",
+			testData: "test_data/request-pv-local.json",
+			settings: Settings{
+				AllowedHostPaths:              settings.AllowedHostPaths,
+				PersistentVolumeHostPathTypes: []string{"Directory"},
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			validateFixture(t, tcase.testData, tcase.settings, nil, tcase.error)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// getPreexistingViolations returns the keys of the violations of the object
+// being replaced by an UPDATE request, see violationKey. Violations already
+// reported for the old object are not enforced on the new one.
+func getPreexistingViolations(validationRequest kubewarden_protocol.ValidationRequest, settings Settings) (map[string]bool, error) {
+	preexistingViolations := make(map[string]bool)
+	oldObject := validationRequest.Request.OldObject
+	if len(oldObject) == 0 || string(oldObject) == "null" {
+		return preexistingViolations, nil
+	}
+
+	podSpecs, err := hostpaths.ExtractPodSpecs(validationRequest.Request.Kind, oldObject, settings)
+	if err != nil {
+		return nil, fmt.Errorf("cannot extract pod specs of the old object: %w", err)
+	}
+
+	for _, podSpec := range podSpecs {
+		mounts, err := getHostPathMounts(podSpec.Spec, validationRequest.Request.Namespace, settings)
+		if err != nil {
+			return nil, err
+		}
+		violations := hostpaths.ValidateMounts(podSpec, mounts, settings)
+		violations = append(violations, hostpaths.EvaluatePrivileges(podSpec, len(mounts) > 0, settings)...)
+		for _, violation := range violations {
+			preexistingViolations[violationKey(violation)] = true
+		}
+	}
+	return preexistingViolations, nil
+}
+
+// violationKey identifies a violation by its pod spec, its rule and its
+// message. A change of the offending mount, or of its container, like its
+// image or its runAsUser, changes the message of the violation.
+func violationKey(violation hostpaths.Violation) string {
+	return violation.PodSpec + "|" + violation.Rule + "|" + violation.Message
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRatcheting(t *testing.T) {
+	allowedHostPaths := []HostPath{
+		{
+			PathPrefix: "/data",
+			ReadOnly:   true,
+		},
+	}
+
+	for _, tcase := range []struct {
+		name     string
+		testData string
+		settings Settings
+		error    string
+	}{
+		{
+			name:     "update without ratcheting",
+			testData: "test_data/request-deployment-update-unchanged-hostpaths.json",
+			settings: Settings{
+				AllowedHostPaths: allowedHostPaths,
+			},
+			error: "hostPath '/data' mounted as 'test-data' should be readOnly 'true'",
+		},
+		{
+			name:     "update with unchanged hostPath mounts",
+			testData: "test_data/request-deployment-update-unchanged-hostpaths.json",
+			settings: Settings{
+				AllowedHostPaths: allowedHostPaths,
+				Ratcheting:       true,
+			},
+		},
+		{
+			name:     "update with new hostPath mount",
+			testData: "test_data/request-deployment-update-new-hostpath.json",
+			settings: Settings{
+				AllowedHostPaths: allowedHostPaths,
+				Ratcheting:       true,
+			},
+			error: "hostPath '/etc' mounted as 'test-etc' is not in the AllowedHostPaths list",
+		},
+		{
+			name:     "update of an object of a kind no longer allowed",
+			testData: "test_data/request-deployment-update-unchanged-hostpaths.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix:   "/data",
+						ReadOnly:     false,
+						AllowedKinds: []string{"DaemonSet"},
+					},
+				},
+				Ratcheting: true,
+			},
+		},
+		{
+			name:     "update of an object no longer pinned to the required nodes",
+			testData: "test_data/request-deployment-update-unchanged-hostpaths.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix:        "/data",
+						ReadOnly:          false,
+						RequireNodeLabels: map[string]string{"node-role.kubernetes.io/storage": "true"},
+					},
+				},
+				Ratcheting: true,
+			},
+		},
+		{
+			name:     "update changing the image of an unchanged mount",
+			testData: "test_data/request-deployment-update-unchanged-hostpaths.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix:    "/data",
+						ReadOnly:      false,
+						AllowedImages: []string{"nginx:1.25"},
+					},
+				},
+				Ratcheting: true,
+			},
+			error: "hostPath '/data' mounted as 'test-data' is not allowed for image 'nginx:1.27' of container 'nginx', only for 'nginx:1.25'",
+		},
+		{
+			name:     "update changing the runAsUser of an unchanged mount",
+			testData: "test_data/request-deployment-update-runasuser-hostpath.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix:   "/data",
+						ReadOnly:     false,
+						MinRunAsUser: ptrInt64(1000),
+					},
+				},
+				Ratcheting: true,
+			},
+			error: "hostPath '/data' mounted as 'test-data' is writable, container 'nginx' should set runAsUser to at least 1000, not 0",
+		},
+		{
+			name:     "create is fully validated",
+			testData: "test_data/request-pod-precedence-least.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/var",
+						ReadOnly:   false,
+					},
+					{
+						PathPrefix: "/var/local",
+						ReadOnly:   true,
+					},
+				},
+				Ratcheting: true,
+			},
+			error: "hostPath '/var/local/aaa' mounted as 'test-var-local-aaa' should be readOnly 'true'",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			validateFixture(t, tcase.testData, tcase.settings, nil, tcase.error)
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kubewarden/go-policy-template/internal/replay"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// The flags of TestReplay, which runs validate natively against a request
+// captured from real traffic:
+//
+//	go test -run '^TestReplay$' -v . -args \
+//		-replay.request=review.json -replay.settings=settings.json
+var (
+	replayRequest = flag.String("replay.request", "",
+		"AdmissionReview, audit event or object validated by TestReplay")
+	replaySettings = flag.String("replay.settings", "",
+		"JSON settings of the policy used by TestReplay")
+	replayResources = flag.String("replay.resources", "",
+		"JSON List of the Kubernetes resources served to the context-aware lookups of TestReplay")
+)
+
+// kubectlRequestBody is the prefix of the request bodies logged by
+// `kubectl -v=8`.
+const kubectlRequestBody = "Request Body: "
+
+func TestReplay(t *testing.T) {
+	if *replayRequest == "" {
+		t.Skip("no -replay.request given")
+	}
+
+	payload, err := os.ReadFile(*replayRequest)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	settings := []byte("{}")
+	if *replaySettings != "" {
+		if settings, err = os.ReadFile(*replaySettings); err != nil {
+			t.Fatalf("Unexpected error %+v", err)
+		}
+	}
+	resources := map[string]string{}
+	if *replayResources != "" {
+		resourcesPayload, err := os.ReadFile(*replayResources)
+		if err != nil {
+			t.Fatalf("Unexpected error %+v", err)
+		}
+		if resources, err = newReplayResources(resourcesPayload); err != nil {
+			t.Fatalf("Unexpected error %+v", err)
+		}
+	}
+
+	settingsResponse, err := validateSettings(settings)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	printReplayResponse(t, "SettingsValidationResponse", settingsResponse)
+	settingsValidation := kubewarden_protocol.SettingsValidationResponse{}
+	if err = json.Unmarshal(settingsResponse, &settingsValidation); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	if !settingsValidation.Valid {
+		t.Fatalf("Settings rejected, the policy cannot be deployed with them")
+	}
+
+	validationRequest, err := newReplayValidationRequest(payload, settings)
+	if errors.Is(err, errReplayNotValidated) {
+		t.Skipf("%v, it is accepted without inspection", err)
+	}
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	host.Client = &fakeKubernetes{resources: resources}
+	defer func() { host.Client = nil }()
+
+	response, err := validate(validationRequest)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	printReplayResponse(t, "ValidationResponse", response)
+}
+
+func printReplayResponse(t *testing.T, name string, response []byte) {
+	t.Helper()
+	indented := bytes.Buffer{}
+	if err := json.Indent(&indented, response, "", "  "); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	fmt.Printf("%s:\n%s\n", name, indented.String())
+}
+
+// replayObject holds the fields shared by the documents accepted by
+// TestReplay.
+type replayObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Items []json.RawMessage `json:"items"`
+
+	// AdmissionReview fields
+	Request json.RawMessage `json:"request"`
+
+	// audit Event fields
+	AuditID string `json:"auditID"`
+	Verb    string `json:"verb"`
+}
+
+// newReplayValidationRequest builds the validation request of the policy from
+// one of:
+//   - an AdmissionReview, whose request is used as is.
+//   - an `audit.k8s.io` Event, logged at the Request or RequestResponse
+//     level.
+//   - a plain object, validated as a CREATE request. The `Request Body`
+//     lines logged by `kubectl -v=8` are accepted too.
+func newReplayValidationRequest(payload []byte, settings []byte) ([]byte, error) {
+	if i := bytes.Index(payload, []byte(kubectlRequestBody)); i >= 0 {
+		payload = payload[i+len(kubectlRequestBody):]
+		if end := bytes.IndexByte(payload, '\n'); end >= 0 {
+			payload = payload[:end]
+		}
+	}
+
+	object := replayObject{}
+	if err := json.Unmarshal(payload, &object); err != nil {
+		return nil, fmt.Errorf("cannot parse replayed request: %w", err)
+	}
+
+	var request json.RawMessage
+	switch {
+	case object.Kind == "AdmissionReview":
+		if len(object.Request) == 0 {
+			return nil, errors.New("AdmissionReview without request")
+		}
+		request = object.Request
+	case object.Kind == "Event" && strings.HasPrefix(object.APIVersion, "audit.k8s.io/"):
+		auditRequest, err := newReplayAuditRequest(object, payload)
+		if err != nil {
+			return nil, err
+		}
+		if request, err = json.Marshal(auditRequest); err != nil {
+			return nil, err
+		}
+	default:
+		group, version, found := strings.Cut(object.APIVersion, "/")
+		if !found {
+			group, version = "", object.APIVersion
+		}
+		plainRequest := kubewarden_protocol.KubernetesAdmissionRequest{
+			Uid: "replay",
+			Kind: kubewarden_protocol.GroupVersionKind{
+				Group:   group,
+				Version: version,
+				Kind:    object.Kind,
+			},
+			Name:      object.Metadata.Name,
+			Namespace: object.Metadata.Namespace,
+			Operation: "CREATE",
+			Object:    payload,
+		}
+		var err error
+		if request, err = json.Marshal(plainRequest); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(map[string]json.RawMessage{
+		"request":  request,
+		"settings": settings,
+	})
+}
+
+// errReplayNotValidated is returned for the audit events of requests that the
+// policy accepts without inspection, see shouldValidate.
+var errReplayNotValidated = errors.New("request not validated by the policy")
+
+// newReplayAuditRequest rebuilds the admission request of an audit event, read
+// like hostpaths-replay does, see replay.ReadAuditLog. Audit events don't hold
+// the old object, UPDATE requests are validated as if the object was new.
+func newReplayAuditRequest(event replayObject, payload []byte) (kubewarden_protocol.KubernetesAdmissionRequest, error) {
+	line := bytes.Buffer{}
+	if err := json.Compact(&line, payload); err != nil {
+		return kubewarden_protocol.KubernetesAdmissionRequest{}, err
+	}
+	events, stats, err := replay.ReadAuditLog(&line)
+	if err != nil {
+		return kubewarden_protocol.KubernetesAdmissionRequest{}, err
+	}
+	switch {
+	case len(events) == 1:
+	case stats.Skipped > 0:
+		return kubewarden_protocol.KubernetesAdmissionRequest{},
+			fmt.Errorf("audit event '%s': %w", event.AuditID, errReplayNotValidated)
+	case stats.Incomplete > 0 && event.Verb == "patch":
+		return kubewarden_protocol.KubernetesAdmissionRequest{},
+			fmt.Errorf("audit event '%s' is a patch without responseObject, it must be logged at the RequestResponse level", event.AuditID)
+	case stats.Incomplete > 0:
+		return kubewarden_protocol.KubernetesAdmissionRequest{},
+			fmt.Errorf("audit event '%s' has no requestObject, it must be logged at the Request level", event.AuditID)
+	default:
+		return kubewarden_protocol.KubernetesAdmissionRequest{},
+			fmt.Errorf("audit event '%s' has no objectRef, or is not logged at the ResponseComplete stage", event.AuditID)
+	}
+	auditEvent := events[0]
+
+	requestObject := replayObject{}
+	if err := json.Unmarshal(auditEvent.Object, &requestObject); err != nil {
+		return kubewarden_protocol.KubernetesAdmissionRequest{}, err
+	}
+	name := auditEvent.Name
+	if name == "" {
+		name = requestObject.Metadata.Name
+	}
+
+	request := kubewarden_protocol.KubernetesAdmissionRequest{
+		Uid: auditEvent.AuditID,
+		Kind: kubewarden_protocol.GroupVersionKind{
+			Group:   auditEvent.APIGroup,
+			Version: auditEvent.APIVersion,
+			Kind:    requestObject.Kind,
+		},
+		SubResource: auditEvent.SubResource,
+		Name:        name,
+		Namespace:   auditEvent.Namespace,
+		Operation:   auditEvent.Operation,
+		Object:      auditEvent.Object,
+	}
+	if len(auditEvent.User) > 0 {
+		if err := json.Unmarshal(auditEvent.User, &request.UserInfo); err != nil {
+			return request, err
+		}
+	}
+	return request, nil
+}
+
+// newReplayResources indexes the objects of the given List like
+// fakeKubernetes expects them.
+func newReplayResources(payload []byte) (map[string]string, error) {
+	list := replayObject{}
+	if err := json.Unmarshal(payload, &list); err != nil {
+		return nil, fmt.Errorf("cannot parse replayed resources: %w", err)
+	}
+
+	resources := make(map[string]string)
+	for _, item := range list.Items {
+		object := replayObject{}
+		if err := json.Unmarshal(item, &object); err != nil {
+			return nil, err
+		}
+		resources[object.Kind+"/"+object.Metadata.Namespace+"/"+object.Metadata.Name] = string(item)
+	}
+	return resources, nil
+}
+
+func TestReplayValidationRequests(t *testing.T) {
+	settings := []byte(`{"allowedHostPaths": [{"pathPrefix": "/var/log", "readOnly": true}]}`)
+
+	for _, tcase := range []struct {
+		name      string
+		testData  string
+		operation string
+		accepted  bool
+	}{
+		{
+			name:      "admission review",
+			testData:  "test_data/replay/admission-review.json",
+			operation: "CREATE",
+			accepted:  false,
+		},
+		{
+			name:      "audit event",
+			testData:  "test_data/replay/audit-event.json",
+			operation: "UPDATE",
+			accepted:  false,
+		},
+		{
+			name:      "patch audit event",
+			testData:  "test_data/replay/audit-event-patch.json",
+			operation: "UPDATE",
+			accepted:  false,
+		},
+		{
+			name:      "kubectl request body",
+			testData:  "test_data/replay/kubectl-v8.log",
+			operation: "CREATE",
+			accepted:  true,
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			payload, err := os.ReadFile(tcase.testData)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			validationRequest, err := newReplayValidationRequest(payload, settings)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+			request := kubewarden_protocol.ValidationRequest{}
+			if err = json.Unmarshal(validationRequest, &request); err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+			if request.Request.Operation != tcase.operation {
+				t.Errorf("Wanted operation %s, but got %s instead", tcase.operation, request.Request.Operation)
+			}
+
+			responsePayload, err := validate(validationRequest)
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+			response := kubewarden_protocol.ValidationResponse{}
+			if err = json.Unmarshal(responsePayload, &response); err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+			if response.Accepted != tcase.accepted {
+				t.Errorf("Wanted accepted %t, but got %+v instead", tcase.accepted, response)
+			}
+		})
+	}
+}
+
+func TestReplayPatchAuditEventWithoutResponseObject(t *testing.T) {
+	payload, err := os.ReadFile("test_data/replay/audit-event-patch.json")
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	event := map[string]json.RawMessage{}
+	if err = json.Unmarshal(payload, &event); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	delete(event, "responseObject")
+	if payload, err = json.Marshal(event); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	_, err = newReplayValidationRequest(payload, []byte(`{}`))
+	expected := "audit event '7a2d9e14-0b6c-4f3a-9e51-3c8b2d6f1a07' is a patch without responseObject, it must be logged at the RequestResponse level"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Wanted error '%s', but got '%v' instead", expected, err)
+	}
+}
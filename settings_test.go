@@ -1,135 +1,59 @@
 package main
 
 import (
+	"encoding/json"
 	"testing"
-)
-
-func TestParsingSettingsWithAllValuesProvidedFromValidationReq(t *testing.T) {
-	request := `
-	{
-		"request": "doesn't matter here",
-		"settings": {
-			"allowedHostPaths": [
-				{
-					"pathPrefix": "/foo",
-					"readOnly": true
-				},
-				{
-					"pathPrefix": "/bar",
-					"readOnly": false
-				}
-			]
-		}
-	}
-	`
-	rawRequest := []byte(request)
-
-	settings, err := NewSettingsFromValidationReq(rawRequest)
-	if err != nil {
-		t.Errorf("Unexpected error %+v", err)
-	}
-
-	if settings.AllowedHostPaths[0].PathPrefix != "/foo" &&
-		settings.AllowedHostPaths[0].ReadOnly != true &&
-		settings.AllowedHostPaths[1].PathPrefix != "/bar" &&
-		settings.AllowedHostPaths[1].ReadOnly != false {
-		t.Errorf("Missing value")
-	}
-}
 
-func TestParsingSettingsWithNoValueProvided(t *testing.T) {
-	request := `
-	{
-		"request": "doesn't matter here",
-		"settings": {
-		}
-	}
-	`
-	rawRequest := []byte(request)
-
-	settings, err := NewSettingsFromValidationReq(rawRequest)
-	if err != nil {
-		t.Errorf("Unexpected error %+v", err)
-	}
-
-	if len(settings.AllowedHostPaths) != 0 {
-		t.Errorf("Expected AllowedHostPaths to be empty")
-	}
-}
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
 
-func TestParsingSettingsWithEntriesMissing(t *testing.T) {
+func TestValidateSettings(t *testing.T) {
 	for _, tcase := range []struct {
-		name    string
-		request string
-		error   string
+		name     string
+		settings string
+		valid    bool
+		message  string
 	}{
 		{
-			name: "missing pathPrefix",
-			request: `
-			{
-				"request": "doesn't matter here",
-				"settings": {
-					"allowedHostPaths": [
-						{
-							"readOnly": true
-						}
-					]
-				}
-			}
-			`,
-			error: "pathPrefix key is missing",
+			name:     "valid settings",
+			settings: `{"allowedHostPaths": [{"pathPrefix": "/foo", "readOnly": true}]}`,
+			valid:    true,
 		},
 		{
-			name: "missing readOnly",
-			request: `
-			{
-				"request": "doesn't matter here",
-				"settings": {
-					"allowedHostPaths": [
-						{
-							"readOnly": true
-						},
-						{
-							"pathPrefix": "/foo"
-						}
-					]
-				}
-			}
-			`,
-			error: "pathPrefix key is missing; readOnly key for pathPrefix '/foo' is missing",
+			name:     "empty settings",
+			settings: `{}`,
+			valid:    true,
+		},
+		{
+			name:     "missing readOnly",
+			settings: `{"allowedHostPaths": [{"pathPrefix": "/foo"}]}`,
+			valid:    false,
+			message:  "/allowedHostPaths/0/readOnly: missing required property",
+		},
+		{
+			name:     "wrong type",
+			settings: `{"allowedHostPaths": [{"pathPrefix": "/foo", "readOnly": true}, {"pathPrefix": "/bar", "readOnly": "true"}]}`,
+			valid:    false,
+			message:  "/allowedHostPaths/1/readOnly: expected boolean",
 		},
 	} {
 		t.Run(tcase.name, func(t *testing.T) {
-			rawRequest := []byte(tcase.request)
+			responsePayload, err := validateSettings([]byte(tcase.settings))
+			if err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
+
+			var response kubewarden_protocol.SettingsValidationResponse
+			if err := json.Unmarshal(responsePayload, &response); err != nil {
+				t.Fatalf("Unexpected error %+v", err)
+			}
 
-			_, err := NewSettingsFromValidationReq(rawRequest)
-			if err == nil {
-				t.Errorf("Wanted error, but no error was found")
+			if response.Valid != tcase.valid {
+				t.Fatalf("Wanted valid '%t', but got '%t' instead", tcase.valid, response.Valid)
 			}
-			if err.Error() != tcase.error {
-				t.Errorf("Wanted error '%s', but got '%s' instead",
-					tcase.error, err.Error())
+			if !tcase.valid && *response.Message != tcase.message {
+				t.Errorf("Wanted message '%s', but got '%s' instead", tcase.message, *response.Message)
 			}
 		})
 	}
 }
-
-func TestEmptySettingsAreValid(t *testing.T) {
-	request := `
-	{
-		"request": "doesn't matter here",
-		"settings": {
-		}
-	}
-	`
-	rawRequest := []byte(request)
-
-	settings, err := NewSettingsFromValidateSettingsPayload(rawRequest)
-	if err != nil {
-		t.Errorf("Unexpected error %+v", err)
-	}
-
-	if !settings.Valid() {
-		t.Errorf("Settings are reported as not valid")
-	}
-}
@@ -3,10 +3,9 @@ package main
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
-	"strings"
 
 	onelog "github.com/francoispqt/onelog"
+	"github.com/kubewarden/go-policy-template/internal/hostpaths"
 	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
 	kubewarden "github.com/kubewarden/policy-sdk-go"
 	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
@@ -29,12 +28,28 @@ func validate(payload []byte) ([]byte, error) {
 			kubewarden.Code(400))
 	}
 
-	if len(settings.AllowedHostPaths) == 0 {
+	if len(settings.AllowedHostPaths) == 0 && !settings.PrivilegeGuardEnabled() &&
+		len(settings.PersistentVolumeHostPathTypes) == 0 {
 		// empty settings, accepting
 		return kubewarden.AcceptRequest()
 	}
 
-	podSpec, err := kubewarden.ExtractPodSpecFromObject(validationRequest)
+	if !shouldValidate(validationRequest.Request) {
+		logger.DebugWithFields("skipping request", func(e onelog.Entry) {
+			e.String("name", validationRequest.Request.Name)
+			e.String("namespace", validationRequest.Request.Namespace)
+			e.String("operation", validationRequest.Request.Operation)
+			e.String("subResource", validationRequest.Request.SubResource)
+		})
+		return kubewarden.AcceptRequest()
+	}
+
+	if validationRequest.Request.Kind.Group == "" && validationRequest.Request.Kind.Kind == "PersistentVolume" {
+		return validatePersistentVolume(validationRequest, settings)
+	}
+
+	podSpecs, err := hostpaths.ExtractPodSpecs(
+		validationRequest.Request.Kind, validationRequest.Request.Object, settings)
 	if err != nil {
 		return kubewarden.RejectRequest(
 			kubewarden.Message(err.Error()),
@@ -44,64 +59,56 @@ func validate(payload []byte) ([]byte, error) {
 	logger.DebugWithFields("validating pod object", func(e onelog.Entry) {
 		e.String("name", validationRequest.Request.Name)
 		e.String("namespace", validationRequest.Request.Namespace)
+		e.Bool("dryRun", validationRequest.Request.DryRun)
 	})
 
-	volumes := make([]*corev1.Volume, 0)
-	for _, volume := range podSpec.Volumes {
-		if volume.HostPath != nil {
-			volumes = append(volumes, volume)
+	var preexistingViolations map[string]bool
+	if settings.Ratcheting && validationRequest.Request.Operation == "UPDATE" {
+		preexistingViolations, err = getPreexistingViolations(validationRequest, settings)
+		if err != nil {
+			return kubewarden.RejectRequest(
+				kubewarden.Message(err.Error()),
+				kubewarden.Code(400))
 		}
 	}
 
-	volumeMounts := make([]*corev1.VolumeMount, 0)
-	volumeMounts = append(volumeMounts, getVolumeMounts(podSpec.InitContainers)...)
-	volumeMounts = append(volumeMounts, getVolumeMounts(podSpec.Containers)...)
+	errs := make([]error, 0)
+	for _, podSpec := range podSpecs {
+		mounts, err := getHostPathMounts(podSpec.Spec, validationRequest.Request.Namespace, settings)
+		if err != nil {
+			return kubewarden.RejectRequest(
+				kubewarden.Message(err.Error()),
+				kubewarden.NoCode)
+		}
 
-	for _, volume := range volumes {
-		for _, mount := range volumeMounts {
-			if *volume.Name != *mount.Name {
-				// volume and mount don't match, skip
+		for _, violation := range hostpaths.ValidateMounts(podSpec, mounts, settings) {
+			if preexistingViolations[violationKey(violation)] {
+				logger.WarnWithFields("ignoring pre-existing violation", func(e onelog.Entry) {
+					e.String("name", validationRequest.Request.Name)
+					e.String("namespace", validationRequest.Request.Namespace)
+					e.String("violation", violation.Error())
+				})
 				continue
 			}
-			match := false
-			var errsMount error // all errors of current mount
-			// readOnly attribute of most specific AllowedHostPath takes precendence:
-			previousAllowedHostPath := ""
-			for _, allowedHostPath := range settings.AllowedHostPaths {
-				if hasPathPrefix(*volume.HostPath.Path, allowedHostPath.PathPrefix) {
-					// current setting allowedHostPath matches path of volumeMount
-					if hasPathPrefix(allowedHostPath.PathPrefix, previousAllowedHostPath) {
-						// allowedHostPath is more specific (and has precendence over
-						//	past allowedHostPath), or the same path
-						match = true
-						validationError := validatePath(*volume.HostPath.Path, *mount.Name, mount.ReadOnly, allowedHostPath)
-						// build all errors for this mount:
-						if validationError == nil {
-							// drop errors in errsMount, we found a more
-							// specific path that validates the current
-							// mount
-							errsMount = nil
-						} else {
-							// we found even more errors for this specific mount, append
-							errsMount = errors.Join(errsMount, validationError)
-						}
-						previousAllowedHostPath = allowedHostPath.PathPrefix
-					}
-				}
-			}
-			// concat to global err:
-			err = errors.Join(err, errsMount)
-			if !match {
-				// path didn't match against any PathPrefix in settings
-				err = errors.Join(err, fmt.Errorf("hostPath '%s' mounted as '%s' is not in the AllowedHostPaths list",
-					*volume.HostPath.Path, *mount.Name))
+			errs = append(errs, violation)
+		}
+		for _, violation := range hostpaths.EvaluatePrivileges(podSpec, len(mounts) > 0, settings) {
+			if settings.PrivilegeGuardWarnOnly || preexistingViolations[violationKey(violation)] {
+				logger.WarnWithFields("privileged container", func(e onelog.Entry) {
+					e.String("name", validationRequest.Request.Name)
+					e.String("namespace", validationRequest.Request.Namespace)
+					e.String("violation", violation.Error())
+				})
+				continue
 			}
+			errs = append(errs, violation)
 		}
 	}
-	if err != nil {
+	if err = errors.Join(errs...); err != nil {
 		logger.DebugWithFields("rejecting pod object", func(e onelog.Entry) {
 			e.String("name", validationRequest.Request.Name)
 			e.String("namespace", validationRequest.Request.Namespace)
+			e.Bool("dryRun", validationRequest.Request.DryRun)
 		})
 		return kubewarden.RejectRequest(
 			kubewarden.Message(err.Error()),
@@ -111,38 +118,32 @@ func validate(payload []byte) ([]byte, error) {
 	return kubewarden.AcceptRequest()
 }
 
-// validatePath validates the path prefix and its readOnly state against the
-// passed hostPath, and returns a matching error if failed.
-func validatePath(path, mountName string, readOnly bool, hostPath HostPath) (err error) {
-	if hasPathPrefix(path, hostPath.PathPrefix) {
-		if readOnly != hostPath.ReadOnly {
-			return fmt.Errorf("hostPath '%s' mounted as '%s' should be readOnly '%t'",
-				path, mountName, hostPath.ReadOnly)
-		}
+// shouldValidate returns whether the request can change the hostPath volumes
+// used by the object. Only CREATE and UPDATE operations are validated, the
+// requests without operation are considered as CREATE ones. Requests to a
+// subresource are accepted without inspection, except for the
+// `ephemeralcontainers` one, which adds containers to a running pod.
+func shouldValidate(request kubewarden_protocol.KubernetesAdmissionRequest) bool {
+	switch request.Operation {
+	case "CREATE", "UPDATE", "":
+	default:
+		return false
 	}
-	return nil
+	return request.SubResource == "" || request.SubResource == "ephemeralcontainers"
 }
 
-func hasPathPrefix(path string, prefix string) bool {
-	// allow "/foo", "/foo/", "/foo/bar", etc
-	// disallow "/fool", "/etc/foo", etc
-	// "/foo/../" is never valid.
-	// Hence, ensure paths terminate in `/`:
-	pathTerminated := path
-	if !strings.HasSuffix(pathTerminated, "/") {
-		pathTerminated = pathTerminated + "/"
-	}
-	prefixTerminated := prefix
-	if !strings.HasSuffix(prefixTerminated, "/") {
-		prefixTerminated = prefixTerminated + "/"
+// getHostPathMounts returns all the mounts of the hostPath volumes of the
+// given pod spec, including the PersistentVolumes backed by a path of the
+// node when the settings require to check them.
+func getHostPathMounts(podSpec corev1.PodSpec, namespace string, settings Settings) ([]hostpaths.Mount, error) {
+	volumes := hostpaths.HostPathVolumes(podSpec)
+	if settings.CheckPersistentVolumeClaims {
+		persistentVolumes, err := getPersistentHostPathVolumes(podSpec, namespace)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, persistentVolumes...)
 	}
-	return strings.HasPrefix(pathTerminated, prefixTerminated)
-}
 
-func getVolumeMounts(containers []*corev1.Container) []*corev1.VolumeMount {
-	volumeMounts := make([]*corev1.VolumeMount, 0)
-	for _, container := range containers {
-		volumeMounts = append(volumeMounts, container.VolumeMounts...)
-	}
-	return volumeMounts
+	return hostpaths.Mounts(podSpec, volumes), nil
 }
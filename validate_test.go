@@ -15,6 +15,48 @@ func ptrString(s string) *string {
 	return &s
 }
 
+func ptrInt64(i int64) *int64 {
+	return &i
+}
+
+// validateFixture validates the request of the given fixture with the given
+// settings, and checks it is rejected with the expected message, or
+// accepted when the message is empty. The context-aware lookups are served
+// by the given fakeKubernetes, when not nil.
+func validateFixture(t *testing.T, testData string, settings Settings, kubernetes *fakeKubernetes, expectedError string) {
+	t.Helper()
+	if kubernetes != nil {
+		host.Client = kubernetes
+		defer func() { host.Client = nil }()
+	}
+	payload, err := kubewarden_testing.BuildValidationRequestFromFixture(testData, &settings)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	responsePayload, err := validate(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+	var response kubewarden_protocol.ValidationResponse
+	if err := json.Unmarshal(responsePayload, &response); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	if expectedError == "" {
+		if !response.Accepted {
+			t.Errorf("Unexpected rejection: %s", *response.Message)
+		}
+		return
+	}
+	if response.Accepted {
+		t.Fatalf("Unexpected approval")
+	}
+	if *response.Message != expectedError {
+		t.Errorf("Wanted '%s', but got '%s' instead", expectedError, *response.Message)
+	}
+}
+
 func TestEmptySettingsLeadsToApproval(t *testing.T) {
 	settings := Settings{}
 
@@ -164,6 +206,28 @@ func TestRejection(t *testing.T) {
 			},
 			error: "hostPath '/data' mounted as 'test-data' is not in the AllowedHostPaths list",
 		},
+		{
+			name:     "volumeMount /data is only allowed for DaemonSets",
+			testData: "test_data/request-pod-hostpaths.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix:   "/data",
+						ReadOnly:     true,
+						AllowedKinds: []string{"DaemonSet"},
+					},
+					{
+						PathPrefix: "/var",
+						ReadOnly:   false,
+					},
+					{
+						PathPrefix: "/var/local/aaa",
+						ReadOnly:   false,
+					},
+				},
+			},
+			error: "hostPath '/data' mounted as 'test-data' is not allowed for kind 'Pod', only for 'DaemonSet'",
+		},
 		{
 			name:     "volumeMount /data should be readWrite",
 			testData: "test_data/request-pod-hostpaths.json",
@@ -186,6 +250,49 @@ func TestRejection(t *testing.T) {
 			},
 			error: "hostPath '/data' mounted as 'test-data' should be readOnly 'false'",
 		},
+		{
+			name:     "writable volumeMount /data/writer used by root",
+			testData: "test_data/request-pod-writable-root.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix:          "/data",
+						ReadOnly:            false,
+						RequireRunAsNonRoot: true,
+						MinRunAsUser:        ptrInt64(1000),
+					},
+				},
+			},
+			error: "hostPath '/data/writer' mounted as 'data' is writable, container 'writer' should set runAsNonRoot 'true'\n" +
+				"hostPath '/data/writer' mounted as 'data' is writable, container 'writer' should set runAsUser to at least 1000, not 0",
+		},
+		{
+			name:     "volumeMount /var contains the read-only /var/local",
+			testData: "test_data/request-pod-hostpaths.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/data",
+						ReadOnly:   true,
+					},
+					{
+						PathPrefix: "/var",
+						ReadOnly:   false,
+					},
+					{
+						PathPrefix: "/var/local",
+						ReadOnly:   true,
+					},
+					{
+						PathPrefix: "/var/local/aaa",
+						ReadOnly:   false,
+					},
+				},
+				ProtectReadOnlySubPaths: true,
+			},
+			error: "hostPath '/var' mounted as 'test-var' should be readOnly 'true', it contains the read-only allowed path '/var/local'\n" +
+				"hostPath '/var' mounted as 'test-var' should be readOnly 'true', it contains the read-only allowed path '/var/local'",
+		},
 		{
 			name:     "volumeMount /var/local/aaa should be readOnly",
 			testData: "test_data/request-pod-hostpaths.json",
@@ -614,3 +721,343 @@ func TestWorkloadTypes(t *testing.T) {
 		})
 	}
 }
+
+func TestPodSpecPaths(t *testing.T) {
+	for _, tcase := range []struct {
+		name     string
+		testData string
+		settings Settings
+		error    string
+	}{
+		{
+			name:     "custom resource with pod spec path",
+			testData: "test_data/request-rollout-hostpaths.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/var",
+						ReadOnly:   true,
+					},
+				},
+				PodSpecPaths: []PodSpecPath{
+					{
+						Group: "argoproj.io",
+						Kind:  "Rollout",
+						Paths: []string{"spec.template.spec"},
+					},
+				},
+			},
+			error: "spec.template.spec: hostPath '/data' mounted as 'test-data' is not in the AllowedHostPaths list",
+		},
+		{
+			name:     "custom resource with pod spec path accepted",
+			testData: "test_data/request-rollout-hostpaths.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/data",
+						ReadOnly:   false,
+					},
+					{
+						PathPrefix: "/var",
+						ReadOnly:   true,
+					},
+				},
+				PodSpecPaths: []PodSpecPath{
+					{
+						Group:   "argoproj.io",
+						Version: "v1alpha1",
+						Kind:    "Rollout",
+						Paths:   []string{"spec.template.spec"},
+					},
+				},
+			},
+		},
+		{
+			name:     "custom resource without pod spec path",
+			testData: "test_data/request-rollout-hostpaths.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/var",
+						ReadOnly:   true,
+					},
+				},
+				PodSpecPaths: []PodSpecPath{
+					{
+						Group:   "argoproj.io",
+						Version: "v1",
+						Kind:    "Rollout",
+						Paths:   []string{"spec.template.spec"},
+					},
+				},
+			},
+			error: "object should be one of these kinds: " +
+				"Deployment, ReplicaSet, StatefulSet, DaemonSet, ReplicationController, Job, CronJob, Pod",
+		},
+		{
+			name:     "pod template",
+			testData: "test_data/request-podtemplate-hostpaths.json",
+			settings: Settings{
+				AllowedHostPaths: []HostPath{
+					{
+						PathPrefix: "/data",
+						ReadOnly:   true,
+					},
+				},
+			},
+			error: "template.spec: hostPath '/data' mounted as 'test-data' should be readOnly 'true'",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			validateFixture(t, tcase.testData, tcase.settings, nil, tcase.error)
+		})
+	}
+}
+
+func TestPodSpecPathsWithQuery(t *testing.T) {
+	settings := Settings{
+		AllowedHostPaths: []HostPath{
+			{
+				PathPrefix: "/var",
+				ReadOnly:   true,
+			},
+		},
+		PodSpecPaths: []PodSpecPath{
+			{
+				Group: "example.com",
+				Kind:  "PodSet",
+				Paths: []string{"spec.workloads.#.template.spec"},
+			},
+		},
+	}
+
+	validateFixture(t, "test_data/request-podset-hostpaths.json", settings, nil,
+		"spec.workloads.#.template.spec[1]: hostPath '/etc' mounted as 'test-etc' is not in the AllowedHostPaths list")
+}
+
+func TestOperationsAndSubResources(t *testing.T) {
+	settings := Settings{
+		AllowedHostPaths: []HostPath{
+			{
+				PathPrefix: "/data",
+				ReadOnly:   true,
+			},
+			{
+				PathPrefix: "/var",
+				ReadOnly:   false,
+			},
+		},
+	}
+	restrictiveSettings := Settings{
+		AllowedHostPaths: []HostPath{
+			{
+				PathPrefix: "/data",
+				ReadOnly:   true,
+			},
+		},
+	}
+
+	for _, tcase := range []struct {
+		name     string
+		testData string
+		settings Settings
+		error    string
+	}{
+		{
+			name:     "status subresource is not inspected",
+			testData: "test_data/request-pod-status-subresource.json",
+			settings: restrictiveSettings,
+		},
+		{
+			name:     "scale subresource is not inspected",
+			testData: "test_data/request-deployment-scale-subresource.json",
+			settings: restrictiveSettings,
+		},
+		{
+			name:     "delete is not inspected",
+			testData: "test_data/request-pod-delete.json",
+			settings: restrictiveSettings,
+		},
+		{
+			name:     "unknown operation is not inspected",
+			testData: "test_data/request-pod-connect.json",
+			settings: restrictiveSettings,
+		},
+		{
+			name:     "dry run is validated",
+			testData: "test_data/request-pod-dryrun.json",
+			settings: restrictiveSettings,
+			error: "hostPath '/var' mounted as 'test-var' is not in the AllowedHostPaths list\n" +
+				"hostPath '/var' mounted as 'test-var' is not in the AllowedHostPaths list\n" +
+				"hostPath '/var/local/aaa' mounted as 'test-var-local-aaa' is not in the AllowedHostPaths list\n" +
+				"hostPath '/var/local/aaa' mounted as 'test-var-local-aaa' is not in the AllowedHostPaths list",
+		},
+		{
+			name:     "pod without ephemeral containers",
+			testData: "test_data/request-pod-hostpaths.json",
+			settings: settings,
+		},
+		{
+			name:     "ephemeralcontainers subresource is validated",
+			testData: "test_data/request-pod-ephemeralcontainers-subresource.json",
+			settings: settings,
+			error:    "hostPath '/data' mounted as 'test-data' should be readOnly 'true'",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			validateFixture(t, tcase.testData, tcase.settings, nil, tcase.error)
+		})
+	}
+}
+
+func TestPrivilegeGuard(t *testing.T) {
+	privileged := "container 'app' is privileged, it can mount any host path"
+	sysAdmin := "container 'sidecar' adds the 'CAP_SYS_ADMIN' capability, it can mount any host path"
+
+	for _, tcase := range []struct {
+		name                  string
+		testData              string
+		privilegeGuard        string
+		warnOnly              bool
+		dangerousCapabilities []string
+		// emptyAllowList clears the allowedHostPaths list
+		emptyAllowList bool
+		error          string
+	}{
+		{
+			name:           "disabled",
+			testData:       "test_data/request-pod-privileged.json",
+			privilegeGuard: "",
+		},
+		{
+			name:           "never",
+			testData:       "test_data/request-pod-privileged.json",
+			privilegeGuard: "never",
+		},
+		{
+			name:           "pod using hostPath volumes",
+			testData:       "test_data/request-pod-privileged.json",
+			privilegeGuard: "hostPaths",
+			error:          privileged + "\n" + sysAdmin,
+		},
+		{
+			name:           "pod without hostPath volumes",
+			testData:       "test_data/request-pod-privileged-no-hostpaths.json",
+			privilegeGuard: "hostPaths",
+		},
+		{
+			name:           "always",
+			testData:       "test_data/request-pod-privileged-no-hostpaths.json",
+			privilegeGuard: "always",
+			error:          privileged + "\n" + sysAdmin,
+		},
+		{
+			name:           "warn only",
+			testData:       "test_data/request-pod-privileged.json",
+			privilegeGuard: "always",
+			warnOnly:       true,
+		},
+		{
+			name:                  "custom capabilities",
+			testData:              "test_data/request-pod-privileged.json",
+			privilegeGuard:        "hostPaths",
+			dangerousCapabilities: []string{"net_admin"},
+			error:                 privileged + "\n" + "container 'sidecar' adds the 'NET_ADMIN' capability, it can mount any host path",
+		},
+		{
+			name:           "empty allow list",
+			testData:       "test_data/request-pod-privileged.json",
+			privilegeGuard: "always",
+			emptyAllowList: true,
+			error:          privileged + "\n" + sysAdmin,
+		},
+		{
+			name:           "empty allow list without guard",
+			testData:       "test_data/request-pod-privileged.json",
+			emptyAllowList: true,
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			settings := Settings{
+				AllowedHostPaths:       []HostPath{{PathPrefix: "/var/log", ReadOnly: true}},
+				PrivilegeGuard:         tcase.privilegeGuard,
+				PrivilegeGuardWarnOnly: tcase.warnOnly,
+				DangerousCapabilities:  tcase.dangerousCapabilities,
+			}
+			if tcase.emptyAllowList {
+				settings.AllowedHostPaths = []HostPath{}
+			}
+			validateFixture(t, tcase.testData, settings, nil, tcase.error)
+		})
+	}
+}
+
+func TestRuntimeClasses(t *testing.T) {
+	relaxed := []HostPath{
+		{PathPrefix: "/data", ReadOnly: true},
+		{PathPrefix: "/data", ReadOnly: false, AllowedRuntimeClasses: []string{"gvisor", "kata"}},
+	}
+	// the restricted entry overrides the other one, whatever their order
+	relaxedFirst := []HostPath{relaxed[1], relaxed[0]}
+	sandboxedOnly := []HostPath{
+		{PathPrefix: "/data", ReadOnly: false, AllowedRuntimeClasses: []string{"gvisor"}},
+	}
+
+	for _, tcase := range []struct {
+		name             string
+		testData         string
+		allowedHostPaths []HostPath
+		error            string
+	}{
+		{
+			name:             "relaxed for the sandboxed RuntimeClass",
+			testData:         "test_data/request-pod-runtimeclass-gvisor.json",
+			allowedHostPaths: relaxed,
+		},
+		{
+			name:             "not relaxed for another RuntimeClass",
+			testData:         "test_data/request-pod-runtimeclass-runc.json",
+			allowedHostPaths: relaxed,
+			error:            "hostPath '/data/writer' mounted as 'data' should be readOnly 'true'",
+		},
+		{
+			name:             "not relaxed for the default RuntimeClass",
+			testData:         "test_data/request-pod-runtimeclass-default.json",
+			allowedHostPaths: relaxed,
+			error:            "hostPath '/data/writer' mounted as 'data' should be readOnly 'true'",
+		},
+		{
+			name:             "relaxed first for the sandboxed RuntimeClass",
+			testData:         "test_data/request-pod-runtimeclass-gvisor.json",
+			allowedHostPaths: relaxedFirst,
+		},
+		{
+			name:             "relaxed first, not for another RuntimeClass",
+			testData:         "test_data/request-pod-runtimeclass-runc.json",
+			allowedHostPaths: relaxedFirst,
+			error:            "hostPath '/data/writer' mounted as 'data' should be readOnly 'true'",
+		},
+		{
+			name:             "sandboxed RuntimeClass only",
+			testData:         "test_data/request-pod-runtimeclass-gvisor.json",
+			allowedHostPaths: sandboxedOnly,
+		},
+		{
+			name:             "another RuntimeClass refused",
+			testData:         "test_data/request-pod-runtimeclass-runc.json",
+			allowedHostPaths: sandboxedOnly,
+			error:            "hostPath '/data/writer' mounted as 'data' is not allowed for runtimeClassName 'runc', only for 'gvisor'",
+		},
+		{
+			name:             "default RuntimeClass refused",
+			testData:         "test_data/request-pod-runtimeclass-default.json",
+			allowedHostPaths: sandboxedOnly,
+			error:            "hostPath '/data/writer' mounted as 'data' is not allowed for the default runtimeClassName, only for 'gvisor'",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			validateFixture(t, tcase.testData, Settings{AllowedHostPaths: tcase.allowedHostPaths}, nil, tcase.error)
+		})
+	}
+}